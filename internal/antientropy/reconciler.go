@@ -0,0 +1,180 @@
+package antientropy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/taitelee/kvstore/internal/hashing"
+	"github.com/taitelee/kvstore/internal/kv"
+)
+
+// Peer is the anti-entropy surface a remote node exposes, backing the
+// RootHash/BucketHash/LeafDiff RPCs, a way to fetch the full Operation for
+// a key once a differing leaf has been found, and a way to push a newer
+// Operation back to a replica that read-repair found to be stale.
+type Peer interface {
+	RootHash(ctx context.Context) (Digest, error)
+	BucketHash(ctx context.Context, bucket int) (Digest, error)
+	LeafDiff(ctx context.Context, bucket int) (map[string]Digest, error)
+	FetchOperation(ctx context.Context, key string) (kv.Operation, bool, error)
+	PushOperation(ctx context.Context, op kv.Operation) error
+}
+
+// PeerDialer resolves a ring NodeID to a Peer. Production code backs this
+// with the same RPC transport the coordinator uses; tests can supply an
+// in-process fake.
+type PeerDialer interface {
+	Dial(node hashing.NodeID) (Peer, error)
+}
+
+// Reconciler compares this node's Merkle tree against a peer's, one pass per
+// ReconcileWith call, and repairs any divergence it finds by pulling the newer Operation
+// through Engine.Import, which already discards stale versions, so a
+// reconciliation pass is safe to re-run or interrupt at any point.
+type Reconciler struct {
+	tree   *Tree
+	self   hashing.NodeID
+	sel    hashing.NodeSelector
+	dialer PeerDialer
+	engine *kv.Engine
+
+	tombstones *TombstoneGC
+}
+
+// NewReconciler constructs a Reconciler for this node.
+func NewReconciler(tree *Tree, self hashing.NodeID, sel hashing.NodeSelector, dialer PeerDialer, engine *kv.Engine) *Reconciler {
+	return &Reconciler{
+		tree:       tree,
+		self:       self,
+		sel:        sel,
+		dialer:     dialer,
+		engine:     engine,
+		tombstones: NewTombstoneGC(),
+	}
+}
+
+// ReconcileWith runs one reconciliation pass against a single peer: compare
+// root hashes, then bucket hashes, then individual leaves, descending only
+// as far as needed to find the keys that actually differ.
+func (r *Reconciler) ReconcileWith(ctx context.Context, peer hashing.NodeID) error {
+	client, err := r.dialer.Dial(peer)
+	if err != nil {
+		return fmt.Errorf("antientropy: dial %s: %w", peer, err)
+	}
+
+	remoteRoot, err := client.RootHash(ctx)
+	if err != nil {
+		return fmt.Errorf("antientropy: root hash from %s: %w", peer, err)
+	}
+	if remoteRoot == r.tree.RootHash() {
+		return nil // replicas already agree on everything
+	}
+
+	for i := 0; i < NumBuckets; i++ {
+		if err := r.reconcileBucket(ctx, peer, client, i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Reconciler) reconcileBucket(ctx context.Context, peer hashing.NodeID, client Peer, bucketIdx int) error {
+	remoteHash, err := client.BucketHash(ctx, bucketIdx)
+	if err != nil {
+		return fmt.Errorf("antientropy: bucket hash %d from %s: %w", bucketIdx, peer, err)
+	}
+	if remoteHash == r.tree.BucketHash(bucketIdx) {
+		return nil
+	}
+
+	remoteLeaves, err := client.LeafDiff(ctx, bucketIdx)
+	if err != nil {
+		return fmt.Errorf("antientropy: leaf diff %d from %s: %w", bucketIdx, peer, err)
+	}
+	localLeaves := r.tree.LeafDiff(bucketIdx)
+
+	for key, remoteLeafHash := range remoteLeaves {
+		if localLeaves[key] == remoteLeafHash {
+			continue
+		}
+
+		// Only pull keys this node is actually responsible for; a
+		// differing leaf for a key we don't replicate just means the
+		// ring moved on and rebalance (not anti-entropy) owns it.
+		if !r.owns(key) {
+			continue
+		}
+
+		op, found, err := client.FetchOperation(ctx, key)
+		if err != nil {
+			return fmt.Errorf("antientropy: fetch %s from %s: %w", key, peer, err)
+		}
+		if !found {
+			continue
+		}
+
+		// Engine.Import -> ApplyReplica already compares against the
+		// local Version and drops the op if it isn't strictly newer,
+		// so importing unconditionally here is safe and idempotent.
+		if err := r.engine.Import(op); err != nil {
+			return fmt.Errorf("antientropy: import %s from %s: %w", key, peer, err)
+		}
+
+		if op.IsDelete() {
+			r.tombstones.Observed(key, peer)
+		}
+	}
+
+	// Any local tombstone this peer's leaves agree with has now been
+	// observed by peer, bringing it one replica closer to GC-eligible.
+	for key, localHash := range localLeaves {
+		if remoteLeaves[key] == localHash {
+			r.tombstones.Observed(key, peer)
+		}
+	}
+
+	return nil
+}
+
+// Tombstones exposes the reconciler's tombstone confirmation tracker, for a
+// compactor to consult before physically dropping a deleted key.
+func (r *Reconciler) Tombstones() *TombstoneGC {
+	return r.tombstones
+}
+
+func (r *Reconciler) owns(key string) bool {
+	if r.sel.IsPrimary(key) {
+		return true
+	}
+	for _, node := range r.sel.Replicas(key) {
+		if node == r.self {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadRepair is invoked by a coordinator when a quorum read observes
+// disagreeing Record.Versions across replicas: newest is the Operation
+// reconstructed from the replica with the greatest Version (per
+// Version.GreaterThan), and staleReplicas lists the others. Each stale
+// replica is brought up to date asynchronously, since the read itself
+// already has its answer and shouldn't wait on the repair.
+func (r *Reconciler) ReadRepair(ctx context.Context, newest kv.Operation, staleReplicas []hashing.NodeID) {
+	for _, node := range staleReplicas {
+		if node == r.self {
+			continue
+		}
+
+		go func(node hashing.NodeID) {
+			client, err := r.dialer.Dial(node)
+			if err != nil {
+				return
+			}
+			// Best-effort: a failed read-repair write is caught by the
+			// next anti-entropy pass, so errors here are not fatal.
+			_ = client.PushOperation(ctx, newest)
+		}(node)
+	}
+}
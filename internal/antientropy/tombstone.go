@@ -0,0 +1,68 @@
+package antientropy
+
+import (
+	"sync"
+
+	"github.com/taitelee/kvstore/internal/hashing"
+)
+
+// TombstoneGC tracks, per key, which replicas have been confirmed (via
+// anti-entropy agreeing on that key's leaf hash) to have observed the
+// current tombstone. A tombstone must not be physically dropped until
+// every live replica has confirmed it — otherwise a replica that never
+// saw the delete can later anti-entropy an old Put back in and resurrect
+// the key.
+type TombstoneGC struct {
+	mu       sync.Mutex
+	observed map[string]map[hashing.NodeID]bool
+}
+
+// NewTombstoneGC constructs an empty TombstoneGC.
+func NewTombstoneGC() *TombstoneGC {
+	return &TombstoneGC{
+		observed: make(map[string]map[hashing.NodeID]bool),
+	}
+}
+
+// Observed records that peer's leaf hash for key matched ours during a
+// reconciliation pass, i.e. peer has seen the current version of key
+// (tombstone or otherwise).
+func (g *TombstoneGC) Observed(key string, peer hashing.NodeID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	peers, ok := g.observed[key]
+	if !ok {
+		peers = make(map[hashing.NodeID]bool)
+		g.observed[key] = peers
+	}
+	peers[peer] = true
+}
+
+// Forget drops tracking state for key, e.g. once it has actually been
+// purged or a new write superseded the tombstone being tracked.
+func (g *TombstoneGC) Forget(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.observed, key)
+}
+
+// ReadyToPurge reports whether every replica in liveReplicas has confirmed
+// it has observed key's current version, meaning a tombstone for key is
+// safe to physically remove from the store.
+func (g *TombstoneGC) ReadyToPurge(key string, liveReplicas []hashing.NodeID) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	peers := g.observed[key]
+	if peers == nil {
+		return len(liveReplicas) == 0
+	}
+
+	for _, r := range liveReplicas {
+		if !peers[r] {
+			return false
+		}
+	}
+	return true
+}
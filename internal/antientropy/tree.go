@@ -0,0 +1,207 @@
+// Package antientropy detects and repairs divergence between replicas of
+// the same keyspace using a Merkle tree over (key, version) pairs, plus a
+// read-repair path, called inline by quorum reads that observe disagreeing
+// versions, which updates the stale replicas asynchronously.
+package antientropy
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"sync"
+
+	"github.com/taitelee/kvstore/internal/hashing"
+	"github.com/taitelee/kvstore/internal/kv"
+)
+
+// NumBuckets partitions the keyspace for the Merkle tree. Every key hashes
+// into exactly one bucket; buckets are reconciled independently so a
+// mismatch in one doesn't require touching the rest of the keyspace.
+const NumBuckets = 1024
+
+// Digest is a 32-byte Merkle hash.
+type Digest [32]byte
+
+// leaf is what a single key contributes to its bucket: a hash over the key
+// and the version of the write that produced it, not the value itself, so
+// comparing two replicas' leaves never requires shipping payloads.
+type leaf struct {
+	hash Digest
+}
+
+// bucket holds the leaves that hash into it and a lazily-recomputed root.
+// Root recomputation walks every leaf in the bucket, which is acceptable
+// because a bucket only needs to be rebuilt when one of its own keys
+// changes (dirty) and NumBuckets keeps each bucket's leaf count small.
+type bucket struct {
+	mu     sync.Mutex
+	leaves map[string]leaf
+	root   Digest
+	dirty  bool
+}
+
+// Tree is a per-node Merkle tree over the local keyspace. It is updated
+// incrementally via Observe (wired to Engine.OnApply) and can be rebuilt
+// from scratch via RebuildFromSnapshot, e.g. after loading a checkpoint.
+type Tree struct {
+	buckets [NumBuckets]*bucket
+}
+
+// NewTree constructs an empty Tree.
+func NewTree() *Tree {
+	t := &Tree{}
+	for i := range t.buckets {
+		t.buckets[i] = &bucket{leaves: make(map[string]leaf)}
+	}
+	return t
+}
+
+// Observe updates the tree for a single applied operation. Register it with
+// Engine.OnApply so the tree stays current without a full rescan:
+//
+//	tree := antientropy.NewTree()
+//	engine.OnApply(tree.Observe)
+func (t *Tree) Observe(op kv.Operation) {
+	b := t.buckets[bucketIndex(op.Key)]
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.leaves[op.Key] = leaf{hash: leafHash(op.Key, op.Version)}
+	b.dirty = true
+}
+
+// RebuildFromSnapshot discards all existing leaves and repopulates every
+// bucket from snap, e.g. after restoring a checkpoint out-of-band from the
+// Engine.OnApply stream.
+func (t *Tree) RebuildFromSnapshot(snap kv.StoreSnapshot) {
+	fresh := make([]map[string]leaf, NumBuckets)
+	for i := range fresh {
+		fresh[i] = make(map[string]leaf)
+	}
+
+	snap.Scan(func(key string, record kv.Record) bool {
+		idx := bucketIndex(key)
+		fresh[idx][key] = leaf{hash: leafHash(key, record.Version)}
+		return true
+	})
+
+	for i, leaves := range fresh {
+		b := t.buckets[i]
+		b.mu.Lock()
+		b.leaves = leaves
+		b.dirty = true
+		b.mu.Unlock()
+	}
+}
+
+// RootHash returns a Digest over every bucket's root, so two replicas with
+// identical state anywhere in the keyspace report the same RootHash.
+func (t *Tree) RootHash() Digest {
+	h := sha256.New()
+	for i := 0; i < NumBuckets; i++ {
+		br := t.BucketHash(i)
+		h.Write(br[:])
+	}
+	var out Digest
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// BucketHash returns the Merkle root of bucket i, recomputing it from its
+// current leaves if anything in the bucket changed since the last call.
+func (t *Tree) BucketHash(i int) Digest {
+	b := t.buckets[i]
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.dirty {
+		return b.root
+	}
+
+	b.root = merkleRoot(b.leaves)
+	b.dirty = false
+	return b.root
+}
+
+// LeafDiff returns a copy of bucket i's (key -> leaf hash) map, for a
+// reconciler to compare against a peer's leaves once BucketHash has
+// revealed the two replicas disagree somewhere in that bucket.
+func (t *Tree) LeafDiff(i int) map[string]Digest {
+	b := t.buckets[i]
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make(map[string]Digest, len(b.leaves))
+	for k, v := range b.leaves {
+		out[k] = v.hash
+	}
+	return out
+}
+
+// merkleRoot folds a bucket's leaves into a single Digest. Leaves are
+// sorted by key first so the result is independent of map iteration order.
+func merkleRoot(leaves map[string]leaf) Digest {
+	if len(leaves) == 0 {
+		return Digest{}
+	}
+
+	keys := make([]string, 0, len(leaves))
+	for k := range leaves {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	level := make([]Digest, len(keys))
+	for i, k := range keys {
+		level[i] = leaves[k].hash
+	}
+
+	for len(level) > 1 {
+		next := make([]Digest, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i]) // odd one out carries up unchanged
+				continue
+			}
+			h := sha256.New()
+			h.Write(level[i][:])
+			h.Write(level[i+1][:])
+			var d Digest
+			copy(d[:], h.Sum(nil))
+			next = append(next, d)
+		}
+		level = next
+	}
+
+	return level[0]
+}
+
+// leafHash hashes a (key, version) pair, deliberately excluding the value
+// so diffing never needs to move payloads until a real divergence is found.
+func leafHash(key string, v kv.Version) Digest {
+	h := sha256.New()
+	h.Write([]byte(key))
+	h.Write([]byte(v.NodeID))
+	var seqBuf [8]byte
+	binary.BigEndian.PutUint64(seqBuf[:], v.Seq)
+	h.Write(seqBuf[:])
+
+	var out Digest
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// bucketIndexShift takes the top bits of a hashing.HashKey hash that select
+// a bucket: NumBuckets (1024 == 1<<10) buckets means the top 10 bits.
+const bucketIndexShift = 32 - 10
+
+// bucketIndex partitions a key across NumBuckets using the top bits of
+// hashing.HashKey — the ring's own hash space — rather than an unrelated
+// hash, so a bucket's keyspace lines up with a contiguous range of ring
+// ownership instead of scattering it across the whole ring.
+func bucketIndex(key string) int {
+	return int(hashing.HashKey(key) >> bucketIndexShift)
+}
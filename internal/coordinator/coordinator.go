@@ -0,0 +1,266 @@
+// Package coordinator implements Dynamo-style tunable-quorum reads and
+// writes on top of a hashing.NodeSelector: it fans a request out to the
+// selector's targets over a pluggable Transport, waits for W/R responses,
+// and resolves conflicts by Version rather than by trusting a single node.
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/taitelee/kvstore/internal/antientropy"
+	"github.com/taitelee/kvstore/internal/hashing"
+	"github.com/taitelee/kvstore/internal/kv"
+)
+
+var (
+	// ErrNoWriteTargets means the selector returned no healthy replicas
+	// to write to (e.g. the whole replica set is down).
+	ErrNoWriteTargets = errors.New("coordinator: no write targets available")
+
+	// ErrNoReadTargets is the read-path equivalent of ErrNoWriteTargets.
+	ErrNoReadTargets = errors.New("coordinator: no read targets available")
+
+	// ErrWriteQuorumFailed means fewer than SelectorConfig.W replicas
+	// acked a write.
+	ErrWriteQuorumFailed = errors.New("coordinator: write quorum not reached")
+
+	// ErrReadFailed means every read target answered with an error (as
+	// opposed to answering cleanly with "no such key"), so the caller
+	// can't trust a miss as a real miss.
+	ErrReadFailed = errors.New("coordinator: no read target answered")
+)
+
+// writeResult is one replica's answer to a fanned-out write.
+type writeResult struct {
+	node hashing.NodeID
+	err  error
+}
+
+// Transport is how a Coordinator reaches other nodes. Engine's local
+// mutations are the leaf a Transport implementation calls into: a Get
+// forwards to Engine.Get (or a remote RPC equivalent), an Apply forwards
+// to Engine.ApplyReplica.
+type Transport interface {
+	Get(ctx context.Context, node hashing.NodeID, key string) (kv.Record, bool, error)
+	Apply(ctx context.Context, node hashing.NodeID, op kv.Operation) error
+}
+
+// HintSink accepts writes a Coordinator couldn't deliver to every replica,
+// for later replay once the replica recovers. The hints package (a
+// dedicated on-disk queue) is the production implementation; Coordinator
+// only depends on this narrow interface so it can be tested without one.
+type HintSink interface {
+	Enqueue(target hashing.NodeID, op kv.Operation) error
+}
+
+// Coordinator consumes a NodeSelector and Transport to implement tunable
+// quorum reads and writes. It assigns each write its own Version — self is
+// this coordinator's NodeID in the Version sense, the same way Engine
+// stamps locally-originated ops with its own NodeID.
+type Coordinator struct {
+	self       hashing.NodeID
+	sel        hashing.NodeSelector
+	transport  Transport
+	cfg        hashing.SelectorConfig
+	hints      HintSink                // nil is valid: unreached replicas are simply not hinted
+	reconciler *antientropy.Reconciler // nil is valid: no read-repair is triggered
+
+	seq uint64
+}
+
+// New constructs a Coordinator. hints and reconciler may be nil; both are
+// best-effort conveniences a caller can wire in, not requirements for
+// quorum reads/writes to function.
+func New(self hashing.NodeID, sel hashing.NodeSelector, transport Transport, cfg hashing.SelectorConfig, hints HintSink, reconciler *antientropy.Reconciler) *Coordinator {
+	return &Coordinator{
+		self:       self,
+		sel:        sel,
+		transport:  transport,
+		cfg:        cfg,
+		hints:      hints,
+		reconciler: reconciler,
+	}
+}
+
+func (c *Coordinator) newVersion() kv.Version {
+	seq := atomic.AddUint64(&c.seq, 1)
+	return kv.Version{NodeID: string(c.self), Seq: seq}
+}
+
+// Put fans a put out to every write target, reports success once W have
+// acked, and hints any target it couldn't reach.
+func (c *Coordinator) Put(ctx context.Context, key string, value []byte) error {
+	return c.write(ctx, kv.Operation{Type: kv.OpPut, Key: key, Value: value, Version: c.newVersion()})
+}
+
+// Delete is Put's tombstone counterpart.
+func (c *Coordinator) Delete(ctx context.Context, key string) error {
+	return c.write(ctx, kv.Operation{Type: kv.OpDelete, Key: key, Version: c.newVersion()})
+}
+
+func (c *Coordinator) write(ctx context.Context, op kv.Operation) error {
+	targets := c.sel.WriteTargets(op.Key)
+	if len(targets) == 0 {
+		return ErrNoWriteTargets
+	}
+
+	want := c.cfg.W
+	if want <= 0 {
+		want = len(targets)
+	}
+
+	results := make(chan writeResult, len(targets))
+	for _, node := range targets {
+		go func(node hashing.NodeID) {
+			results <- writeResult{node: node, err: c.transport.Apply(ctx, node, op)}
+		}(node)
+	}
+
+	var acked, answered int
+	var unreached []hashing.NodeID
+
+	// Stop as soon as W acks are in rather than waiting on every target:
+	// that's the latency payoff of a tunable W<N, and a single slow
+	// straggler shouldn't hold up every write.
+	for answered < len(targets) && acked < want {
+		res := <-results
+		answered++
+		if res.err == nil {
+			acked++
+		} else {
+			unreached = append(unreached, res.node)
+		}
+	}
+
+	if acked < want {
+		return fmt.Errorf("coordinator: only %d/%d replicas acked write to %q (want W=%d): %w", acked, len(targets), op.Key, want, ErrWriteQuorumFailed)
+	}
+
+	if remaining := len(targets) - answered; remaining > 0 {
+		go c.drainStragglers(op, results, remaining, unreached)
+	} else {
+		c.hintUnreached(op, unreached)
+	}
+
+	return nil
+}
+
+// drainStragglers finishes collecting the write results write didn't wait
+// around for once it already had W acks, then hints whichever targets never
+// came back clean.
+func (c *Coordinator) drainStragglers(op kv.Operation, results <-chan writeResult, remaining int, unreached []hashing.NodeID) {
+	for i := 0; i < remaining; i++ {
+		res := <-results
+		if res.err != nil {
+			unreached = append(unreached, res.node)
+		}
+	}
+	c.hintUnreached(op, unreached)
+}
+
+// hintUnreached best-effort enqueues op for every target a write couldn't
+// confirm. A failed enqueue is no worse than not having the hints subsystem
+// at all, and anti-entropy will eventually reconcile the target regardless.
+func (c *Coordinator) hintUnreached(op kv.Operation, unreached []hashing.NodeID) {
+	if c.hints == nil {
+		return
+	}
+	for _, node := range unreached {
+		_ = c.hints.Enqueue(node, op)
+	}
+}
+
+// readResult is one replica's answer to a fanned-out Get.
+type readResult struct {
+	node hashing.NodeID
+	rec  kv.Record
+	ok   bool
+	err  error
+}
+
+// Get fans a read out to R targets, returns the value with the greatest
+// Version, and triggers async read-repair against any replica that
+// answered with an older version or no value at all.
+func (c *Coordinator) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	targets := c.sel.ReadTargets(key)
+	if len(targets) == 0 {
+		return nil, false, ErrNoReadTargets
+	}
+
+	results := make(chan readResult, len(targets))
+	for _, node := range targets {
+		go func(node hashing.NodeID) {
+			rec, ok, err := c.transport.Get(ctx, node, key)
+			results <- readResult{node: node, rec: rec, ok: ok, err: err}
+		}(node)
+	}
+
+	responses := make([]readResult, 0, len(targets))
+	var (
+		best     kv.Record
+		bestOK   bool
+		bestFrom hashing.NodeID
+		errCount int
+	)
+
+	for range targets {
+		res := <-results
+		if res.err != nil {
+			errCount++
+			continue
+		}
+		responses = append(responses, res)
+
+		if res.ok && (!bestOK || res.rec.Version.GreaterThan(best.Version)) {
+			best = res.rec
+			bestOK = true
+			bestFrom = res.node
+		}
+	}
+
+	if !bestOK {
+		if errCount == len(targets) {
+			return nil, false, fmt.Errorf("coordinator: all %d read targets failed for %q: %w", len(targets), key, ErrReadFailed)
+		}
+		return nil, false, nil
+	}
+
+	c.triggerReadRepair(ctx, key, best, bestFrom, responses)
+
+	if best.IsDeleted() {
+		return nil, false, nil
+	}
+	return best.Value, true, nil
+}
+
+func (c *Coordinator) triggerReadRepair(ctx context.Context, key string, best kv.Record, bestFrom hashing.NodeID, responses []readResult) {
+	if c.reconciler == nil {
+		return
+	}
+
+	var stale []hashing.NodeID
+	for _, res := range responses {
+		if res.node == bestFrom {
+			continue
+		}
+		if !res.ok || res.rec.Version.LessThan(best.Version) {
+			stale = append(stale, res.node)
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	op := kv.Operation{Key: key, Version: best.Version}
+	if best.IsDeleted() {
+		op.Type = kv.OpDelete
+	} else {
+		op.Type = kv.OpPut
+		op.Value = best.Value
+	}
+
+	c.reconciler.ReadRepair(ctx, op, stale)
+}
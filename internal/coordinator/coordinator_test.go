@@ -0,0 +1,307 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taitelee/kvstore/internal/antientropy"
+	"github.com/taitelee/kvstore/internal/hashing"
+	"github.com/taitelee/kvstore/internal/kv"
+)
+
+// fakeTransport is an in-memory Transport backed by a map per node, with
+// per-node error injection and artificial latency so tests can force some
+// targets to answer slower than others (straggler draining) or not at all
+// (quorum failure, hinting).
+type fakeTransport struct {
+	mu       sync.Mutex
+	state    map[hashing.NodeID]map[string]kv.Record
+	applyErr map[hashing.NodeID]error
+	getErr   map[hashing.NodeID]error
+	delay    map[hashing.NodeID]time.Duration
+	applied  []applyCall
+}
+
+type applyCall struct {
+	node hashing.NodeID
+	op   kv.Operation
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{
+		state:    make(map[hashing.NodeID]map[string]kv.Record),
+		applyErr: make(map[hashing.NodeID]error),
+		getErr:   make(map[hashing.NodeID]error),
+		delay:    make(map[hashing.NodeID]time.Duration),
+	}
+}
+
+func (f *fakeTransport) Get(ctx context.Context, node hashing.NodeID, key string) (kv.Record, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.getErr[node]; err != nil {
+		return kv.Record{}, false, err
+	}
+	rec, ok := f.state[node][key]
+	return rec, ok, nil
+}
+
+func (f *fakeTransport) Apply(ctx context.Context, node hashing.NodeID, op kv.Operation) error {
+	if d := f.delayFor(node); d > 0 {
+		time.Sleep(d)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.applied = append(f.applied, applyCall{node: node, op: op})
+
+	if err := f.applyErr[node]; err != nil {
+		return err
+	}
+	if f.state[node] == nil {
+		f.state[node] = make(map[string]kv.Record)
+	}
+	f.state[node][op.Key] = kv.Record{Value: op.Value, Version: op.Version, Tombstone: op.IsDelete()}
+	return nil
+}
+
+func (f *fakeTransport) delayFor(node hashing.NodeID) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.delay[node]
+}
+
+func (f *fakeTransport) setApplyErr(node hashing.NodeID, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.applyErr[node] = err
+}
+
+func (f *fakeTransport) setGetErr(node hashing.NodeID, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.getErr[node] = err
+}
+
+func (f *fakeTransport) setDelay(node hashing.NodeID, d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay[node] = d
+}
+
+func (f *fakeTransport) put(node hashing.NodeID, key string, rec kv.Record) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.state[node] == nil {
+		f.state[node] = make(map[string]kv.Record)
+	}
+	f.state[node][key] = rec
+}
+
+// fakeHintSink records every enqueued hint for later assertion.
+type fakeHintSink struct {
+	mu     sync.Mutex
+	hinted []hashing.NodeID
+}
+
+func (h *fakeHintSink) Enqueue(target hashing.NodeID, op kv.Operation) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hinted = append(h.hinted, target)
+	return nil
+}
+
+func (h *fakeHintSink) snapshot() []hashing.NodeID {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]hashing.NodeID, len(h.hinted))
+	copy(out, h.hinted)
+	return out
+}
+
+// fixedSelector is a minimal hashing.NodeSelector that always returns the
+// same target lists, letting coordinator tests fix N/W/R targets directly
+// rather than depending on ring hashing to land a key on particular nodes.
+type fixedSelector struct {
+	write []hashing.NodeID
+	read  []hashing.NodeID
+}
+
+func (s fixedSelector) Primary(string) hashing.NodeID        { return s.write[0] }
+func (s fixedSelector) Replicas(string) []hashing.NodeID     { return s.write }
+func (s fixedSelector) IsPrimary(string) bool                { return true }
+func (s fixedSelector) WriteTargets(string) []hashing.NodeID { return s.write }
+func (s fixedSelector) ReadTargets(string) []hashing.NodeID  { return s.read }
+
+func TestPutSucceedsOnceWAcksArrive(t *testing.T) {
+	transport := newFakeTransport()
+	hints := &fakeHintSink{}
+	sel := fixedSelector{write: []hashing.NodeID{"A", "B", "C"}}
+
+	c := New("self", sel, transport, hashing.SelectorConfig{N: 3, W: 2}, hints, nil)
+
+	if err := c.Put(context.Background(), "k", []byte("v")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+}
+
+func TestPutFailsQuorumWhenTooFewAck(t *testing.T) {
+	transport := newFakeTransport()
+	transport.setApplyErr("A", errors.New("down"))
+	transport.setApplyErr("B", errors.New("down"))
+	sel := fixedSelector{write: []hashing.NodeID{"A", "B", "C"}}
+
+	c := New("self", sel, transport, hashing.SelectorConfig{N: 3, W: 2}, nil, nil)
+
+	err := c.Put(context.Background(), "k", []byte("v"))
+	if !errors.Is(err, ErrWriteQuorumFailed) {
+		t.Fatalf("Put: got %v, want ErrWriteQuorumFailed", err)
+	}
+}
+
+// TestPutHintsStragglersAfterQuorum checks that a target which hasn't
+// answered by the time W acks arrive is still hinted once it does finish,
+// via the background drain path rather than blocking the caller on it.
+func TestPutHintsStragglersAfterQuorum(t *testing.T) {
+	transport := newFakeTransport()
+	transport.setApplyErr("C", errors.New("down"))
+	transport.setDelay("C", 50*time.Millisecond) // answers late, after W is satisfied by A/B
+	hints := &fakeHintSink{}
+	sel := fixedSelector{write: []hashing.NodeID{"A", "B", "C"}}
+
+	c := New("self", sel, transport, hashing.SelectorConfig{N: 3, W: 2}, hints, nil)
+
+	if err := c.Put(context.Background(), "k", []byte("v")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, node := range hints.snapshot() {
+			if node == "C" {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected straggler C to be hinted once drainStragglers observed its failure")
+}
+
+func TestGetReturnsNewestVersionAmongReplicas(t *testing.T) {
+	transport := newFakeTransport()
+	transport.put("A", "k", kv.Record{Value: []byte("old"), Version: kv.Version{NodeID: "n", Seq: 1}})
+	transport.put("B", "k", kv.Record{Value: []byte("new"), Version: kv.Version{NodeID: "n", Seq: 2}})
+	sel := fixedSelector{read: []hashing.NodeID{"A", "B"}}
+
+	c := New("self", sel, transport, hashing.SelectorConfig{N: 2, R: 2}, nil, nil)
+
+	value, ok, err := c.Get(context.Background(), "k")
+	if err != nil || !ok || string(value) != "new" {
+		t.Fatalf("Get: got (%q, %v, %v), want (%q, true, nil)", value, ok, err, "new")
+	}
+}
+
+func TestGetReturnsErrReadFailedWhenAllTargetsError(t *testing.T) {
+	transport := newFakeTransport()
+	transport.setGetErr("A", errors.New("down"))
+	transport.setGetErr("B", errors.New("down"))
+	sel := fixedSelector{read: []hashing.NodeID{"A", "B"}}
+
+	c := New("self", sel, transport, hashing.SelectorConfig{N: 2, R: 2}, nil, nil)
+
+	_, ok, err := c.Get(context.Background(), "k")
+	if ok {
+		t.Fatalf("Get: expected ok=false when every target errored")
+	}
+	if !errors.Is(err, ErrReadFailed) {
+		t.Fatalf("Get: got err %v, want ErrReadFailed", err)
+	}
+}
+
+func TestGetReturnsMissNotErrorWhenNoReplicaHasKey(t *testing.T) {
+	transport := newFakeTransport()
+	sel := fixedSelector{read: []hashing.NodeID{"A", "B"}}
+
+	c := New("self", sel, transport, hashing.SelectorConfig{N: 2, R: 2}, nil, nil)
+
+	_, ok, err := c.Get(context.Background(), "missing")
+	if ok || err != nil {
+		t.Fatalf("Get: got (ok=%v, err=%v), want (false, nil) for a genuine miss", ok, err)
+	}
+}
+
+// TestGetTriggersReadRepairOnStaleReplica wires a real antientropy.Reconciler
+// (with a fake PeerDialer) into the coordinator and checks that a replica
+// answering with a stale version gets pushed the winning operation.
+func TestGetTriggersReadRepairOnStaleReplica(t *testing.T) {
+	ctx := context.Background()
+
+	transport := newFakeTransport()
+	transport.put("A", "k", kv.Record{Value: []byte("old"), Version: kv.Version{NodeID: "n", Seq: 1}})
+	transport.put("B", "k", kv.Record{Value: []byte("new"), Version: kv.Version{NodeID: "n", Seq: 2}})
+	sel := fixedSelector{read: []hashing.NodeID{"A", "B"}}
+
+	staleWAL, err := kv.OpenWAL(t.TempDir(), kv.WALConfig{})
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	staleEngine, err := kv.NewEngine(kv.EngineConfig{NodeID: "A"}, kv.NewStore(), staleWAL, nil)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	dialer := &singlePeerDialer{peer: &pushOnlyPeer{engine: staleEngine}}
+	reconciler := antientropy.NewReconciler(antientropy.NewTree(), "self", nil, dialer, nil)
+
+	c := New("self", sel, transport, hashing.SelectorConfig{N: 2, R: 2}, nil, reconciler)
+
+	if _, _, err := c.Get(ctx, "k"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if value, ok := staleEngine.Get(ctx, "k"); ok && string(value) == "new" {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected read repair to push the winning value to the stale replica")
+}
+
+// singlePeerDialer always resolves to the same Peer, enough for a
+// single-stale-replica read-repair test.
+type singlePeerDialer struct {
+	peer antientropy.Peer
+}
+
+func (d *singlePeerDialer) Dial(hashing.NodeID) (antientropy.Peer, error) {
+	return d.peer, nil
+}
+
+// pushOnlyPeer implements antientropy.Peer far enough to exercise
+// ReadRepair, which only ever calls PushOperation.
+type pushOnlyPeer struct {
+	engine *kv.Engine
+}
+
+func (p *pushOnlyPeer) RootHash(ctx context.Context) (antientropy.Digest, error) {
+	return antientropy.Digest{}, nil
+}
+func (p *pushOnlyPeer) BucketHash(ctx context.Context, bucket int) (antientropy.Digest, error) {
+	return antientropy.Digest{}, nil
+}
+func (p *pushOnlyPeer) LeafDiff(ctx context.Context, bucket int) (map[string]antientropy.Digest, error) {
+	return nil, nil
+}
+func (p *pushOnlyPeer) FetchOperation(ctx context.Context, key string) (kv.Operation, bool, error) {
+	return kv.Operation{}, false, nil
+}
+func (p *pushOnlyPeer) PushOperation(ctx context.Context, op kv.Operation) error {
+	return p.engine.Import(op)
+}
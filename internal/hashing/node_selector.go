@@ -23,18 +23,51 @@ type WriteConsistency int
 const (
 	ReadPrimary ReadConsistency = iota
 	ReadAnyReplica
+
+	// ReadQuorum fans a read out to multiple replicas; the coordinator
+	// package is what actually waits for SelectorConfig.R responses and
+	// picks the newest Version. ReadTargets just returns the candidate
+	// set (up to R healthy replicas) for it to query.
+	ReadQuorum
 )
 
 const (
 	WritePrimaryOnly WriteConsistency = iota
 	WriteReplicate
+
+	// WriteQuorum fans a write out to all healthy replicas; the
+	// coordinator waits for SelectorConfig.W acks before reporting
+	// success and hints the rest. WriteTargets returns every healthy
+	// replica, same as WriteReplicate.
+	WriteQuorum
 )
 
 // SelectorConfig defines routing policy.
 type SelectorConfig struct {
 	ReplicationFactor int
-	ReadConsistency   ReadConsistency
-	WriteConsistency  WriteConsistency
+
+	// N, W, and R are the Dynamo-style tunable quorum parameters consumed
+	// by the coordinator package: N is the replica set size (falling back
+	// to ReplicationFactor when zero, kept for existing callers), W is
+	// how many acks a quorum write needs, and R is how many replicas a
+	// quorum read queries. Operators may set W+R>N for strong consistency
+	// at the cost of availability; nothing here enforces that tradeoff,
+	// it's a deployment choice.
+	N int
+	W int
+	R int
+
+	ReadConsistency  ReadConsistency
+	WriteConsistency WriteConsistency
+}
+
+// replicaCount returns the configured replica set size, falling back to
+// ReplicationFactor for callers that only set the older field.
+func (c SelectorConfig) replicaCount() int {
+	if c.N > 0 {
+		return c.N
+	}
+	return c.ReplicationFactor
 }
 
 // selector is the concrete NodeSelector implementation.
@@ -65,7 +98,7 @@ func (s *selector) Primary(key string) NodeID {
 }
 
 func (s *selector) Replicas(key string) []NodeID {
-	return s.ring.GetReplicas(key, s.cfg.ReplicationFactor)
+	return s.ring.GetReplicas(key, s.cfg.replicaCount())
 }
 
 func (s *selector) IsPrimary(key string) bool {
@@ -90,7 +123,7 @@ func (s *selector) WriteTargets(key string) []NodeID {
 		// primary down means its not a safe write target
 		return nil
 
-	case WriteReplicate:
+	case WriteReplicate, WriteQuorum:
 		targets := make([]NodeID, 0, len(replicas))
 		for _, node := range replicas {
 			if s.health.IsHealthy(node) {
@@ -128,6 +161,23 @@ func (s *selector) ReadTargets(key string) []NodeID {
 		}
 		return nil
 
+	case ReadQuorum:
+		want := s.cfg.R
+		if want <= 0 {
+			want = len(replicas)
+		}
+
+		targets := make([]NodeID, 0, want)
+		for _, node := range replicas {
+			if len(targets) == want {
+				break
+			}
+			if s.health.IsHealthy(node) {
+				targets = append(targets, node)
+			}
+		}
+		return targets
+
 	default:
 		return nil
 	}
@@ -1,14 +1,14 @@
 package hashing
 
 import (
+	"fmt"
 	"hash/fnv"
 	"sort"
 	"sync"
-	"fmt"
 )
 
 // NodeID identifies a node on the ring
-type NodeID string 
+type NodeID string
 
 type Ring interface {
 	AddNode(id NodeID, weight int)
@@ -16,149 +16,189 @@ type Ring interface {
 	GetPrimary(key string) NodeID
 	GetReplicas(key string, n int) []NodeID
 	Nodes() []NodeID
+
+	// Boundaries returns the sorted, deduplicated hash values of every
+	// virtual node on the ring. Consecutive boundaries (wrapping around
+	// from the last back to the first) delimit the maximal hash ranges
+	// that always resolve to the same replica set — the unit the
+	// rebalance package diffs when the ring changes, since it has no
+	// occasion to hash an actual key for every point in the space.
+	Boundaries() []uint32
+
+	// ReplicasAt resolves the replica set that owns a raw hash value, the
+	// same way GetReplicas resolves a key's hash. It lets a caller that
+	// already has a hash (e.g. a Boundaries() entry) avoid reimplementing
+	// the ring's walk-and-dedupe logic.
+	ReplicasAt(hash uint32, n int) []NodeID
 }
 
 // implements the Ring interface
 type ring struct {
-	mu sync.RWMutex
-	entries []entry
+	mu       sync.RWMutex
+	entries  []entry
 	replicas int
 }
 
 // entries are essentially virtual nodes
 type entry struct {
-    hash uint32
-    id   NodeID
+	hash uint32
+	id   NodeID
 }
 
 func NewRing(replicationFactor int) Ring {
 	return &ring{
 		replicas: replicationFactor,
-		entries: make([]entry, 0),
+		entries:  make([]entry, 0),
 	}
 }
 
 // adding physical node to the ring with corresponding number of virtual nodes
 func (r *ring) AddNode(id NodeID, weight int) {
-    r.mu.Lock()
-    defer r.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
 	for i := 0; i < weight; i++ {
 		virtualID := fmt.Sprintf("%s#%d", id, i)
-		
+
 		h := hashID(virtualID)
 
 		r.entries = append(r.entries, entry{
-			hash:	h,
-			id:		id,
+			hash: h,
+			id:   id,
 		})
 	}
 
 	// keep entries sorted so we can binary search.
-    sort.Slice(r.entries, func(i, j int) bool {
-        return r.entries[i].hash < r.entries[j].hash
-    })
+	sort.Slice(r.entries, func(i, j int) bool {
+		return r.entries[i].hash < r.entries[j].hash
+	})
 }
 
 // remove all virtual nodes belonging to a physical node
 func (r *ring) RemoveNode(id NodeID) {
-    r.mu.Lock()
-    defer r.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-    kept := r.entries[:0] // keep same capacity as r, but with length zero
+	kept := r.entries[:0] // keep same capacity as r, but with length zero
 	// NOTE: splices keep a reference to the original array, and the array exists at least one splice references it
-    for _, e := range r.entries {
-        if e.id != id {
-            kept = append(kept, e)
-        }
-    }
-    r.entries = kept
+	for _, e := range r.entries {
+		if e.id != id {
+			kept = append(kept, e)
+		}
+	}
+	r.entries = kept
 }
 
 func (r *ring) GetPrimary(key string) NodeID {
-    r.mu.RLock()
-    defer r.mu.RUnlock()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-    if len(r.entries) == 0 {
-        return ""
-    }
+	if len(r.entries) == 0 {
+		return ""
+	}
 
-    keyHash := hashID(key)
+	keyHash := hashID(key)
 
 	// perform binary search to get primary node
-    // predicate is r.entries[i].hash >= hash, and search until we find boundary between the predicate being false and true 
+	// predicate is r.entries[i].hash >= hash, and search until we find boundary between the predicate being false and true
 	idx := sort.Search(len(r.entries), func(i int) bool {
-        return r.entries[i].hash >= keyHash
-    })
+		return r.entries[i].hash >= keyHash
+	})
 
-    if idx == len(r.entries) {
-        idx = 0
-    }
+	if idx == len(r.entries) {
+		idx = 0
+	}
 
-    return r.entries[idx].id // return primary node
+	return r.entries[idx].id // return primary node
 }
 
-
 // replicas are not nodes, they are normal key-value pairs stored in other nodes other than the primary
 func (r *ring) GetReplicas(key string, n int) []NodeID {
-    r.mu.Lock()
-    defer r.mu.Unlock()
+	return r.ReplicasAt(hashID(key), n)
+}
+
+// ReplicasAt is GetReplicas for a caller that already has a raw hash value
+// instead of a key (the rebalance package, diffing ring boundaries).
+func (r *ring) ReplicasAt(hash uint32, n int) []NodeID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.entries) == 0 || n <= 0 {
+		return nil
+	}
 
-    if len(r.entries) == 0 || n <= 0 {
-        return nil
-    }
+	// we could (with the way we've implemented locking) call GetPrimary here, but not best practice for deadlock safety and idiomatic Golang lol
+	start := sort.Search(len(r.entries), func(i int) bool {
+		return r.entries[i].hash >= hash
+	})
 
-    keyHash := hashID(key)
+	if start == len(r.entries) {
+		start = 0
+	}
 
-    // we could (with the way we've implemented locking) call GetPrimary here, but not best practice for deadlock safety and idiomatic Golang lol
-    start := sort.Search(len(r.entries), func(i int) bool {
-        return r.entries[i].hash >= keyHash
-    })
+	replicas := make([]NodeID, 0, n)  // splice for replica set (holds physical nodes)
+	seen := make(map[NodeID]struct{}) // deduplication of physical nodes
 
-    if start == len(r.entries) {
-        start = 0
-    }
+	for i := 0; len(replicas) < n && i < len(r.entries); i++ {
+		idx := (start + i) % len(r.entries)
+		nodeID := r.entries[idx].id
 
-    replicas := make([]NodeID, 0, n) // splice for replica set (holds physical nodes)
-    seen := make(map[NodeID]struct{}) // deduplication of physical nodes
+		// Deduplicate physical nodes
+		if _, ok := seen[nodeID]; ok {
+			continue
+		}
 
-    for i := 0; len(replicas) < n && i < len(r.entries); i++ {
-        idx := (start + i) % len(r.entries)
-        nodeID := r.entries[idx].id
+		seen[nodeID] = struct{}{}
+		replicas = append(replicas, nodeID)
+	}
 
-        // Deduplicate physical nodes
-        if _, ok := seen[nodeID]; ok {
-            continue
-        }
+	return replicas
 
-        seen[nodeID] = struct{}{}
-        replicas = append(replicas, nodeID)
-    }
+}
 
-    return replicas
-    
+// Boundaries returns the sorted, deduplicated virtual-node hash values
+// currently on the ring.
+func (r *ring) Boundaries() []uint32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	bounds := make([]uint32, 0, len(r.entries))
+	for i, e := range r.entries {
+		if i > 0 && e.hash == r.entries[i-1].hash {
+			continue
+		}
+		bounds = append(bounds, e.hash)
+	}
+	return bounds
 }
 
 func (r *ring) Nodes() []NodeID {
-    r.mu.Lock()
-    defer r.mu.Unlock()
-
-    nodes := make([]NodeID, 0)
-    seen := make(map[NodeID]struct{})
-
-    for _, e := range(r.entries) {
-        if _, ok := seen[e.id]; ok {
-            continue
-        }
-        seen[e.id] = struct{}{}
-        nodes = append(nodes, e.id)
-    }
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	nodes := make([]NodeID, 0)
+	seen := make(map[NodeID]struct{})
+
+	for _, e := range r.entries {
+		if _, ok := seen[e.id]; ok {
+			continue
+		}
+		seen[e.id] = struct{}{}
+		nodes = append(nodes, e.id)
+	}
 
-    return nodes
+	return nodes
 }
 
 func hashID(s string) uint32 {
 	h := fnv.New32a()
-    _, _ = h.Write([]byte(s))
-    return h.Sum32()	
-}
\ No newline at end of file
+	_, _ = h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// HashKey exposes the ring's key hash to callers outside this package (the
+// rebalance package, deciding which ring range a live key falls into)
+// without duplicating or reimplementing it.
+func HashKey(key string) uint32 {
+	return hashID(key)
+}
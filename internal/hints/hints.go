@@ -0,0 +1,579 @@
+// Package hints implements hinted handoff: writes meant for a replica that
+// is temporarily unreachable are persisted to a per-target on-disk queue
+// and replayed once the target's HealthChecker reports it healthy again.
+//
+// A hint queue reuses the WAL's record framing (length | crc32c | payload)
+// so a crash mid-append leaves the same kind of recoverable torn tail a
+// kv.FileWAL segment would, rather than a corrupt file that has to be
+// discarded wholesale.
+package hints
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/taitelee/kvstore/internal/hashing"
+	"github.com/taitelee/kvstore/internal/kv"
+)
+
+const (
+	hintFilePrefix = "hints-"
+	hintFileSuffix = ".log"
+
+	// defaultMaxPerTarget bounds a single target's queue when Config
+	// doesn't set one explicitly.
+	defaultMaxPerTarget = 10000
+
+	// defaultPollInterval is how often a drain worker checks whether its
+	// target has become healthy again.
+	defaultPollInterval = 5 * time.Second
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Applier delivers a hinted Operation to the node it was originally meant
+// for. In production this forwards to Engine.ApplyReplica (directly, if
+// target is this process, or over RPC otherwise) — the same idempotent,
+// version-checked entry point anti-entropy and rebalance use, so replaying
+// a hint can never regress a replica that already caught up some other way.
+type Applier interface {
+	Apply(ctx context.Context, target hashing.NodeID, op kv.Operation) error
+}
+
+// Config controls queue bounds and the directory hints are persisted under.
+type Config struct {
+	Dir string
+
+	// MaxPerTarget bounds how many hints are queued for a single target
+	// before new ones are dropped. Zero selects defaultMaxPerTarget.
+	MaxPerTarget int
+
+	// MaxAge drops a hint once it has waited longer than this — anti-
+	// entropy will eventually reconcile the target regardless, so an
+	// ancient hint is no longer worth the handoff. Zero disables the
+	// age bound.
+	MaxAge time.Duration
+
+	// PollInterval is how often each target's drain worker rechecks
+	// HealthChecker.IsHealthy. Zero selects defaultPollInterval.
+	PollInterval time.Duration
+}
+
+// Hints is the hinted-handoff subsystem: one on-disk queue and one drain
+// worker per target that has ever had a hint enqueued for it.
+type Hints struct {
+	cfg     Config
+	applier Applier
+	health  hashing.HealthChecker
+
+	mu      sync.Mutex
+	queues  map[hashing.NodeID]*targetQueue
+	dropped int64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open opens (or creates) the hint directory and resumes any queues left
+// over from a previous run, starting a drain worker for each.
+func Open(cfg Config, applier Applier, health hashing.HealthChecker) (*Hints, error) {
+	if cfg.MaxPerTarget <= 0 {
+		cfg.MaxPerTarget = defaultMaxPerTarget
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("hints: create dir %s: %w", cfg.Dir, err)
+	}
+
+	h := &Hints{
+		cfg:     cfg,
+		applier: applier,
+		health:  health,
+		queues:  make(map[hashing.NodeID]*targetQueue),
+		stop:    make(chan struct{}),
+	}
+
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("hints: list dir %s: %w", cfg.Dir, err)
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		target, ok := targetFromFileName(e.Name())
+		if !ok {
+			continue
+		}
+
+		q, err := h.openQueue(target)
+		if err != nil {
+			return nil, err
+		}
+		h.startWorker(q)
+	}
+
+	return h, nil
+}
+
+// Enqueue persists a hint for target, dropping it (and counting the drop)
+// if target's queue is already at capacity. It satisfies the HintSink
+// interface the coordinator package depends on.
+func (h *Hints) Enqueue(target hashing.NodeID, op kv.Operation) error {
+	h.mu.Lock()
+	q, ok := h.queues[target]
+	if !ok {
+		var err error
+		q, err = h.openQueue(target)
+		if err != nil {
+			h.mu.Unlock()
+			return err
+		}
+		h.startWorker(q)
+	}
+	h.mu.Unlock()
+
+	return q.enqueue(hint{op: op, enqueuedAt: h.now()}, h.cfg, &h.dropped)
+}
+
+// now is its own method so a future test can override it; production code
+// always wants the real wall clock.
+func (h *Hints) now() time.Time { return time.Now() }
+
+// Pending reports how many hints are currently queued for node.
+func (h *Hints) Pending(node hashing.NodeID) int {
+	h.mu.Lock()
+	q, ok := h.queues[node]
+	h.mu.Unlock()
+	if !ok {
+		return 0
+	}
+	return q.len()
+}
+
+// DroppedCount reports how many hints have been dropped, either for being
+// enqueued against an already-full queue or for outliving MaxAge.
+func (h *Hints) DroppedCount() int64 {
+	return atomic.LoadInt64(&h.dropped)
+}
+
+// Close stops every drain worker and flushes each queue's buffered writes,
+// so a clean Engine.Close never loses a hint that was already durable.
+func (h *Hints) Close() error {
+	close(h.stop)
+	h.wg.Wait()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var firstErr error
+	for _, q := range h.queues {
+		if err := q.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (h *Hints) openQueue(target hashing.NodeID) (*targetQueue, error) {
+	path := filepath.Join(h.cfg.Dir, fileNameForTarget(target))
+
+	q, err := openTargetQueue(target, path)
+	if err != nil {
+		return nil, err
+	}
+	h.queues[target] = q
+	return q, nil
+}
+
+func (h *Hints) startWorker(q *targetQueue) {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.drainLoop(q)
+	}()
+}
+
+// drainLoop polls until the target is healthy, then replays every queued
+// hint in FIFO order through the Applier. Engine.ApplyReplica (the
+// Applier's usual backing call) rejects anything no longer newer than what
+// the target already has, so replaying is safe even if a hint's target
+// caught up some other way (e.g. anti-entropy) in the meantime.
+func (h *Hints) drainLoop(q *targetQueue) {
+	ticker := time.NewTicker(h.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+
+		q.expire(h.cfg.MaxAge, &h.dropped)
+
+		if !h.health.IsHealthy(q.target) {
+			continue
+		}
+
+		ctx := context.Background()
+		q.drain(ctx, h.applier)
+	}
+}
+
+type hint struct {
+	op         kv.Operation
+	enqueuedAt time.Time
+}
+
+// targetQueue is the persisted, in-memory-mirrored hint queue for one
+// target node.
+type targetQueue struct {
+	target hashing.NodeID
+	path   string
+
+	mu      sync.Mutex
+	file    *os.File
+	buf     *bufio.Writer
+	pending []hint
+}
+
+func openTargetQueue(target hashing.NodeID, path string) (*targetQueue, error) {
+	pending, err := readHints(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("hints: open %s: %w", path, err)
+	}
+
+	return &targetQueue{
+		target:  target,
+		path:    path,
+		file:    f,
+		buf:     bufio.NewWriter(f),
+		pending: pending,
+	}, nil
+}
+
+func (q *targetQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+func (q *targetQueue) enqueue(h hint, cfg Config, dropped *int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) >= cfg.MaxPerTarget {
+		atomic.AddInt64(dropped, 1)
+		return fmt.Errorf("hints: queue for %s is full (%d hints)", q.target, cfg.MaxPerTarget)
+	}
+
+	frame := frameHint(h)
+	if _, err := q.buf.Write(frame); err != nil {
+		return fmt.Errorf("hints: append: %w", err)
+	}
+	if err := q.buf.Flush(); err != nil {
+		return fmt.Errorf("hints: flush: %w", err)
+	}
+	if err := q.file.Sync(); err != nil {
+		return fmt.Errorf("hints: sync: %w", err)
+	}
+
+	q.pending = append(q.pending, h)
+	return nil
+}
+
+// expire drops hints older than maxAge (a no-op when maxAge is zero),
+// counting each one in dropped the same way enqueue counts a hint dropped
+// for exceeding MaxPerTarget, and rewrites the file to match, so an
+// abandoned target's queue doesn't grow forever between recoveries.
+func (q *targetQueue) expire(maxAge time.Duration, dropped *int64) {
+	if maxAge <= 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cutoff := time.Now().Add(-maxAge)
+	kept := q.pending[:0]
+	for _, h := range q.pending {
+		if h.enqueuedAt.After(cutoff) {
+			kept = append(kept, h)
+		} else {
+			atomic.AddInt64(dropped, 1)
+		}
+	}
+	if len(kept) == len(q.pending) {
+		return
+	}
+
+	q.pending = kept
+	q.rewriteLocked()
+}
+
+// drain replays every pending hint through applier. The first failure
+// stops the pass (that hint and everything after it stays queued for the
+// next tick); everything applied successfully before it is removed.
+func (q *targetQueue) drain(ctx context.Context, applier Applier) {
+	q.mu.Lock()
+	pending := append([]hint(nil), q.pending...)
+	q.mu.Unlock()
+
+	applied := 0
+	for _, h := range pending {
+		if err := applier.Apply(ctx, q.target, h.op); err != nil {
+			break
+		}
+		applied++
+	}
+	if applied == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = q.pending[applied:]
+	q.rewriteLocked()
+}
+
+// rewriteLocked rewrites the queue file from q.pending via a temp file and
+// atomic rename, the same crash-safety pattern a checkpoint uses: a crash
+// mid-rewrite leaves either the old file or the new one, never a half
+// written one. Caller must hold q.mu.
+func (q *targetQueue) rewriteLocked() {
+	tmpPath := q.path + ".tmp"
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return // best-effort; the stale on-disk copy is still consistent
+	}
+
+	w := bufio.NewWriter(f)
+	for _, h := range q.pending {
+		if _, err := w.Write(frameHint(h)); err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return
+	}
+
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		return
+	}
+
+	q.file.Close()
+	f2, err := os.OpenFile(q.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return
+	}
+	q.file = f2
+	q.buf = bufio.NewWriter(f2)
+}
+
+func (q *targetQueue) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.buf.Flush(); err != nil {
+		return fmt.Errorf("hints: flush on close: %w", err)
+	}
+	return q.file.Close()
+}
+
+// readHints reads every valid hint from path, stopping (without error, the
+// same torn-write tolerance as kv.FileWAL.Replay) at the first short read
+// or CRC mismatch.
+func readHints(path string) ([]hint, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("hints: open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var hints []hint
+	header := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return hints, nil
+		}
+
+		length := binary.BigEndian.Uint32(header[0:4])
+		wantCRC := binary.BigEndian.Uint32(header[4:8])
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return hints, nil
+		}
+		if crc32.Checksum(payload, crc32cTable) != wantCRC {
+			return hints, nil
+		}
+
+		h, err := decodeHint(payload)
+		if err != nil {
+			return hints, nil
+		}
+		hints = append(hints, h)
+	}
+}
+
+// frameHint wraps an encoded hint in the "length | crc32c | payload" frame.
+func frameHint(h hint) []byte {
+	payload := encodeHint(h)
+	frame := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crc32cTable))
+	copy(frame[8:], payload)
+	return frame
+}
+
+// encodeHint lays out:
+//
+//	int64   enqueuedAt (unix nanoseconds)
+//	byte    op.Type
+//	uint32  len(Version.NodeID) | bytes
+//	uint64  Version.Seq
+//	uint32  len(Key) | bytes
+//	uint32  len(Value) | bytes (0 for deletes)
+func encodeHint(h hint) []byte {
+	op := h.op
+	nodeID := []byte(op.Version.NodeID)
+	key := []byte(op.Key)
+	value := op.Value
+
+	size := 8 + 1 + 4 + len(nodeID) + 8 + 4 + len(key) + 4 + len(value)
+	buf := make([]byte, size)
+
+	i := 0
+	binary.BigEndian.PutUint64(buf[i:], uint64(h.enqueuedAt.UnixNano()))
+	i += 8
+
+	buf[i] = byte(op.Type)
+	i++
+
+	binary.BigEndian.PutUint32(buf[i:], uint32(len(nodeID)))
+	i += 4
+	i += copy(buf[i:], nodeID)
+
+	binary.BigEndian.PutUint64(buf[i:], op.Version.Seq)
+	i += 8
+
+	binary.BigEndian.PutUint32(buf[i:], uint32(len(key)))
+	i += 4
+	i += copy(buf[i:], key)
+
+	binary.BigEndian.PutUint32(buf[i:], uint32(len(value)))
+	i += 4
+	i += copy(buf[i:], value)
+
+	return buf[:i]
+}
+
+func decodeHint(b []byte) (h hint, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("hints: malformed hint record: %v", r)
+		}
+	}()
+
+	i := 0
+	enqueuedAt := int64(binary.BigEndian.Uint64(b[i:]))
+	i += 8
+
+	opType := kv.OpType(b[i])
+	i++
+
+	nodeIDLen := int(binary.BigEndian.Uint32(b[i:]))
+	i += 4
+	nodeID := string(b[i : i+nodeIDLen])
+	i += nodeIDLen
+
+	seq := binary.BigEndian.Uint64(b[i:])
+	i += 8
+
+	keyLen := int(binary.BigEndian.Uint32(b[i:]))
+	i += 4
+	key := string(b[i : i+keyLen])
+	i += keyLen
+
+	valueLen := int(binary.BigEndian.Uint32(b[i:]))
+	i += 4
+	var value []byte
+	if valueLen > 0 {
+		value = make([]byte, valueLen)
+		copy(value, b[i:i+valueLen])
+	}
+	i += valueLen
+
+	op := kv.Operation{
+		Type: opType,
+		Key:  key,
+		Version: kv.Version{
+			NodeID: nodeID,
+			Seq:    seq,
+		},
+	}
+	if opType == kv.OpPut {
+		op.Value = value
+	}
+
+	return hint{op: op, enqueuedAt: time.Unix(0, enqueuedAt)}, nil
+}
+
+// fileNameForTarget and targetFromFileName round-trip a NodeID through a
+// filesystem-safe name, since a NodeID is an arbitrary string that may
+// contain characters a path can't.
+func fileNameForTarget(target hashing.NodeID) string {
+	return hintFilePrefix + url.PathEscape(string(target)) + hintFileSuffix
+}
+
+func targetFromFileName(name string) (hashing.NodeID, bool) {
+	if !strings.HasPrefix(name, hintFilePrefix) || !strings.HasSuffix(name, hintFileSuffix) {
+		return "", false
+	}
+	escaped := strings.TrimSuffix(strings.TrimPrefix(name, hintFilePrefix), hintFileSuffix)
+	target, err := url.PathUnescape(escaped)
+	if err != nil {
+		return "", false
+	}
+	return hashing.NodeID(target), true
+}
@@ -0,0 +1,243 @@
+package hints
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taitelee/kvstore/internal/hashing"
+	"github.com/taitelee/kvstore/internal/kv"
+)
+
+// fakeApplier records every applied op and can be told to fail every apply
+// of a given Version.Seq, to exercise drain's stop-on-first-failure behavior.
+type fakeApplier struct {
+	mu      sync.Mutex
+	applied []kv.Operation
+	failSeq map[uint64]bool
+}
+
+func newFakeApplier() *fakeApplier {
+	return &fakeApplier{failSeq: make(map[uint64]bool)}
+}
+
+func (a *fakeApplier) Apply(ctx context.Context, target hashing.NodeID, op kv.Operation) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.failSeq[op.Version.Seq] {
+		return errDown
+	}
+	a.applied = append(a.applied, op)
+	return nil
+}
+
+// failAt marks a specific Version.Seq to fail every time it's applied,
+// until cleared.
+func (a *fakeApplier) failAt(seq uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.failSeq[seq] = true
+}
+
+func (a *fakeApplier) appliedCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.applied)
+}
+
+var errDown = &applierError{"fake applier: target down"}
+
+type applierError struct{ msg string }
+
+func (e *applierError) Error() string { return e.msg }
+
+// fakeHealth lets a test flip a target's health on and off.
+type fakeHealth struct {
+	mu      sync.Mutex
+	healthy map[hashing.NodeID]bool
+}
+
+func newFakeHealth() *fakeHealth {
+	return &fakeHealth{healthy: make(map[hashing.NodeID]bool)}
+}
+
+func (h *fakeHealth) IsHealthy(node hashing.NodeID) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.healthy[node]
+}
+
+func (h *fakeHealth) setHealthy(node hashing.NodeID, v bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.healthy[node] = v
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("condition not met within %s", timeout)
+}
+
+// TestEnqueueDrainsOnceTargetIsHealthy checks the core handoff path: a hint
+// enqueued for an unhealthy target sits pending until health flips, then the
+// drain worker replays it through the Applier.
+func TestEnqueueDrainsOnceTargetIsHealthy(t *testing.T) {
+	applier := newFakeApplier()
+	health := newFakeHealth()
+
+	h, err := Open(Config{Dir: t.TempDir(), PollInterval: 5 * time.Millisecond}, applier, health)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer h.Close()
+
+	op := kv.Operation{Type: kv.OpPut, Key: "k", Value: []byte("v"), Version: kv.Version{NodeID: "n1", Seq: 1}}
+	if err := h.Enqueue("B", op); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	if got := h.Pending("B"); got != 1 {
+		t.Fatalf("Pending(B) = %d, want 1 before target is healthy", got)
+	}
+
+	health.setHealthy("B", true)
+
+	waitFor(t, 2*time.Second, func() bool { return applier.appliedCount() == 1 })
+	waitFor(t, 2*time.Second, func() bool { return h.Pending("B") == 0 })
+}
+
+// TestEnqueueDropsWhenQueueFull checks that MaxPerTarget is enforced and
+// each drop is counted in DroppedCount.
+func TestEnqueueDropsWhenQueueFull(t *testing.T) {
+	applier := newFakeApplier()
+	health := newFakeHealth()
+
+	h, err := Open(Config{Dir: t.TempDir(), MaxPerTarget: 2, PollInterval: time.Hour}, applier, health)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer h.Close()
+
+	for i := 0; i < 2; i++ {
+		op := kv.Operation{Type: kv.OpPut, Key: "k", Version: kv.Version{NodeID: "n1", Seq: uint64(i + 1)}}
+		if err := h.Enqueue("B", op); err != nil {
+			t.Fatalf("Enqueue %d: %v", i, err)
+		}
+	}
+
+	if err := h.Enqueue("B", kv.Operation{Type: kv.OpPut, Key: "k", Version: kv.Version{NodeID: "n1", Seq: 99}}); err == nil {
+		t.Fatalf("expected Enqueue to fail once the target's queue is full")
+	}
+
+	if got := h.DroppedCount(); got != 1 {
+		t.Fatalf("DroppedCount() = %d, want 1", got)
+	}
+	if got := h.Pending("B"); got != 2 {
+		t.Fatalf("Pending(B) = %d, want 2 (the dropped hint must not be queued)", got)
+	}
+}
+
+// TestOpenResumesQueueFromDisk checks that hints persisted before a restart
+// (Hints.Close, then a fresh Open against the same directory) are not lost,
+// and still drain once the target is healthy under the new Hints.
+func TestOpenResumesQueueFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	applier := newFakeApplier()
+	health := newFakeHealth()
+
+	h1, err := Open(Config{Dir: dir, PollInterval: time.Hour}, applier, health)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	op := kv.Operation{Type: kv.OpPut, Key: "k", Value: []byte("v"), Version: kv.Version{NodeID: "n1", Seq: 1}}
+	if err := h1.Enqueue("B", op); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := h1.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	h2, err := Open(Config{Dir: dir, PollInterval: 5 * time.Millisecond}, applier, health)
+	if err != nil {
+		t.Fatalf("reopen Open: %v", err)
+	}
+	defer h2.Close()
+
+	if got := h2.Pending("B"); got != 1 {
+		t.Fatalf("Pending(B) after reopen = %d, want 1", got)
+	}
+
+	health.setHealthy("B", true)
+	waitFor(t, 2*time.Second, func() bool { return applier.appliedCount() == 1 })
+}
+
+// TestExpireDropsAgedHintsAndCountsThem checks MaxAge eviction: a hint older
+// than MaxAge is dropped by the drain loop's periodic expire pass and counted
+// in DroppedCount, even though it was never at the MaxPerTarget capacity.
+func TestExpireDropsAgedHintsAndCountsThem(t *testing.T) {
+	applier := newFakeApplier()
+	health := newFakeHealth()
+
+	h, err := Open(Config{Dir: t.TempDir(), MaxAge: time.Millisecond, PollInterval: 5 * time.Millisecond}, applier, health)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer h.Close()
+
+	op := kv.Operation{Type: kv.OpPut, Key: "k", Version: kv.Version{NodeID: "n1", Seq: 1}}
+	if err := h.Enqueue("B", op); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	time.Sleep(5 * time.Millisecond) // let the hint age past MaxAge before the first tick
+
+	waitFor(t, 2*time.Second, func() bool { return h.Pending("B") == 0 })
+	if got := h.DroppedCount(); got != 1 {
+		t.Fatalf("DroppedCount() = %d, want 1 for the age-expired hint", got)
+	}
+	if got := applier.appliedCount(); got != 0 {
+		t.Fatalf("appliedCount() = %d, want 0: an expired hint must not be applied", got)
+	}
+}
+
+// TestQueueDrainStopsAtFirstFailureAndLeavesRestQueued drives targetQueue's
+// drain directly (same package), so the first-failure-halts-the-pass
+// behavior can be checked deterministically instead of racing a ticker.
+func TestQueueDrainStopsAtFirstFailureAndLeavesRestQueued(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openTargetQueue("B", dir+"/hints-B.log")
+	if err != nil {
+		t.Fatalf("openTargetQueue: %v", err)
+	}
+	defer q.close()
+
+	cfg := Config{MaxPerTarget: defaultMaxPerTarget}
+	var dropped int64
+	for i := 0; i < 3; i++ {
+		op := kv.Operation{Type: kv.OpPut, Key: "k", Version: kv.Version{NodeID: "n1", Seq: uint64(i + 1)}}
+		if err := q.enqueue(hint{op: op}, cfg, &dropped); err != nil {
+			t.Fatalf("enqueue %d: %v", i, err)
+		}
+	}
+
+	applier := newFakeApplier()
+	applier.failAt(2) // the 1st hint (Seq=1) succeeds, the 2nd (Seq=2) fails and halts the pass
+
+	q.drain(context.Background(), applier)
+
+	if got := applier.appliedCount(); got != 1 {
+		t.Fatalf("appliedCount() = %d, want 1: only the hint before the failure should apply", got)
+	}
+	if got := q.len(); got != 2 {
+		t.Fatalf("q.len() = %d, want 2: the failed hint and everything after it must stay queued", got)
+	}
+}
@@ -0,0 +1,477 @@
+package kv
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	checkpointFilePrefix = "checkpoint-"
+	checkpointFileSuffix = ".snap"
+
+	// checkpointEncodingVersion tags the binary layout of a checkpoint file
+	// so a future change to the format can be detected on load instead of
+	// silently misparsing an older snapshot.
+	checkpointEncodingVersion byte = 1
+)
+
+// Checkpoint snapshots the current store to CheckpointDir and truncates the
+// WAL of everything the new snapshot already covers. It is safe to call
+// concurrently with Put/Delete: the snapshot is a consistent point-in-time
+// view, so writes that land after Checkpoint starts simply aren't in it and
+// stay in the WAL.
+//
+// Checkpointing is a no-op if CheckpointDir is unset.
+func (e *Engine) Checkpoint(ctx context.Context) error {
+	if e.cfg.CheckpointDir == "" {
+		return nil
+	}
+
+	snap := e.Snapshot(ctx)
+
+	watermark := make(Watermark)
+	var entries []checkpointEntry
+	snap.Scan(func(key string, record Record) bool {
+		if record.Version.Seq > watermark[record.Version.NodeID] {
+			watermark[record.Version.NodeID] = record.Version.Seq
+		}
+		entries = append(entries, checkpointEntry{key: key, record: record})
+		return true
+	})
+
+	seq := atomic.AddUint64(&e.checkpointSeq, 1)
+	if err := writeCheckpoint(e.cfg.CheckpointDir, seq, watermark, entries); err != nil {
+		return err
+	}
+
+	if err := e.wal.Truncate(watermark); err != nil {
+		return fmt.Errorf("checkpoint: truncate wal: %w", err)
+	}
+
+	return nil
+}
+
+// loadCheckpoint loads the newest valid checkpoint in CheckpointDir (if any)
+// into e.store and returns its watermark, so NewEngine only has to replay
+// WAL records the checkpoint didn't already capture. It returns an empty
+// Watermark, doing nothing, if CheckpointDir is unset or contains no valid
+// checkpoint.
+func (e *Engine) loadCheckpoint() (Watermark, error) {
+	if e.cfg.CheckpointDir == "" {
+		return Watermark{}, nil
+	}
+
+	if err := os.MkdirAll(e.cfg.CheckpointDir, 0o755); err != nil {
+		return nil, fmt.Errorf("checkpoint: create dir %s: %w", e.cfg.CheckpointDir, err)
+	}
+
+	seqs, err := listCheckpoints(e.cfg.CheckpointDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first: a checkpoint write that crashed before its rename
+	// leaves no file at all (writeCheckpoint only renames once fully
+	// synced), but a corrupt *older* file found here would mean disk
+	// corruption rather than a crash; fall back to the next-newest valid
+	// one rather than failing startup outright.
+	for i := len(seqs) - 1; i >= 0; i-- {
+		watermark, entries, err := readCheckpoint(checkpointPath(e.cfg.CheckpointDir, seqs[i]))
+		if err != nil {
+			continue
+		}
+
+		for _, ent := range entries {
+			e.store.Put(ent.key, ent.record)
+		}
+		e.checkpointSeq = seqs[i]
+		return watermark, nil
+	}
+
+	return Watermark{}, nil
+}
+
+// checkpointEntry is one live (possibly tombstoned) key captured by a
+// checkpoint.
+type checkpointEntry struct {
+	key    string
+	record Record
+}
+
+// writeCheckpoint atomically writes a new checkpoint file: it's built up in
+// a temp file and renamed into place only once fully flushed and fsynced, so
+// a crash mid-write never leaves a partially-written file at the final path.
+// Each frame reuses the WAL's "length | crc32c | payload" framing.
+func writeCheckpoint(dir string, seq uint64, watermark Watermark, entries []checkpointEntry) error {
+	path := checkpointPath(dir, seq)
+	tmpPath := path + ".tmp"
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("checkpoint: create %s: %w", tmpPath, err)
+	}
+	defer os.Remove(tmpPath) // no-op once renamed
+
+	if _, err := f.Write([]byte{checkpointEncodingVersion}); err != nil {
+		f.Close()
+		return fmt.Errorf("checkpoint: write version: %w", err)
+	}
+
+	if _, err := f.Write(frameRecord(encodeWatermark(watermark))); err != nil {
+		f.Close()
+		return fmt.Errorf("checkpoint: write watermark: %w", err)
+	}
+
+	for _, ent := range entries {
+		if _, err := f.Write(frameRecord(encodeCheckpointEntry(ent))); err != nil {
+			f.Close()
+			return fmt.Errorf("checkpoint: write entry %q: %w", ent.key, err)
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("checkpoint: fsync %s: %w", tmpPath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("checkpoint: close %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("checkpoint: rename %s: %w", tmpPath, err)
+	}
+
+	return nil
+}
+
+// readCheckpoint reads a checkpoint file written by writeCheckpoint. Since
+// writeCheckpoint only ever renames a fully-synced file into place, any
+// error here means the file is either foreign or was damaged after the
+// fact, and the caller should fall back to an older checkpoint.
+func readCheckpoint(path string) (Watermark, []checkpointEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("checkpoint: open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, nil, fmt.Errorf("checkpoint: read version: %w", err)
+	}
+	if version != checkpointEncodingVersion {
+		return nil, nil, fmt.Errorf("checkpoint: unsupported encoding version %d", version)
+	}
+
+	watermarkPayload, err := readFrame(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("checkpoint: read watermark: %w", err)
+	}
+	watermark, err := decodeWatermark(watermarkPayload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entries []checkpointEntry
+	for {
+		payload, err := readFrame(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("checkpoint: read entry: %w", err)
+		}
+		ent, err := decodeCheckpointEntry(payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, ent)
+	}
+
+	return watermark, entries, nil
+}
+
+// readFrame reads one "length | crc32c | payload" frame from r, returning
+// io.EOF once the stream is exhausted on a frame boundary. Unlike WAL
+// segment replay, a short read or CRC mismatch here is always an error:
+// writeCheckpoint's temp-file-then-rename means a checkpoint file on disk
+// under its final name was never torn mid-write.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("read frame header: %w", err)
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	wantCRC := binary.BigEndian.Uint32(header[4:8])
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("read frame payload: %w", err)
+	}
+
+	if crc32.Checksum(payload, crc32cTable) != wantCRC {
+		return nil, fmt.Errorf("frame crc mismatch")
+	}
+
+	return payload, nil
+}
+
+// encodeWatermark produces the binary payload for a Watermark:
+//
+//	uint32 count
+//	count * (uint32 len(NodeID) | bytes, uint64 Seq)
+func encodeWatermark(w Watermark) []byte {
+	size := 4
+	for node := range w {
+		size += 4 + len(node) + 8
+	}
+
+	buf := make([]byte, size)
+	i := 0
+	binary.BigEndian.PutUint32(buf[i:], uint32(len(w)))
+	i += 4
+
+	// Map iteration order doesn't matter: the watermark is a set of
+	// per-node maxima, not an ordered log.
+	for node, seq := range w {
+		binary.BigEndian.PutUint32(buf[i:], uint32(len(node)))
+		i += 4
+		i += copy(buf[i:], node)
+
+		binary.BigEndian.PutUint64(buf[i:], seq)
+		i += 8
+	}
+
+	return buf[:i]
+}
+
+func decodeWatermark(b []byte) (w Watermark, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("checkpoint: malformed watermark: %v", r)
+		}
+	}()
+
+	i := 0
+	count := int(binary.BigEndian.Uint32(b[i:]))
+	i += 4
+
+	w = make(Watermark, count)
+	for n := 0; n < count; n++ {
+		nodeLen := int(binary.BigEndian.Uint32(b[i:]))
+		i += 4
+		node := string(b[i : i+nodeLen])
+		i += nodeLen
+
+		seq := binary.BigEndian.Uint64(b[i:])
+		i += 8
+
+		w[node] = seq
+	}
+
+	return w, nil
+}
+
+// encodeCheckpointEntry produces the binary payload for one live key:
+//
+//	uint32 len(key) | bytes
+//	uint32 len(Version.NodeID) | bytes
+//	uint64 Version.Seq
+//	byte   tombstone (0 or 1)
+//	uint32 len(Value) | bytes (0 for tombstones)
+func encodeCheckpointEntry(ent checkpointEntry) []byte {
+	key := []byte(ent.key)
+	nodeID := []byte(ent.record.Version.NodeID)
+	value := ent.record.Value
+
+	size := 4 + len(key) + 4 + len(nodeID) + 8 + 1 + 4 + len(value)
+	buf := make([]byte, size)
+
+	i := 0
+	binary.BigEndian.PutUint32(buf[i:], uint32(len(key)))
+	i += 4
+	i += copy(buf[i:], key)
+
+	binary.BigEndian.PutUint32(buf[i:], uint32(len(nodeID)))
+	i += 4
+	i += copy(buf[i:], nodeID)
+
+	binary.BigEndian.PutUint64(buf[i:], ent.record.Version.Seq)
+	i += 8
+
+	if ent.record.Tombstone {
+		buf[i] = 1
+	}
+	i++
+
+	binary.BigEndian.PutUint32(buf[i:], uint32(len(value)))
+	i += 4
+	i += copy(buf[i:], value)
+
+	return buf[:i]
+}
+
+func decodeCheckpointEntry(b []byte) (ent checkpointEntry, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("checkpoint: malformed entry: %v", r)
+		}
+	}()
+
+	i := 0
+	keyLen := int(binary.BigEndian.Uint32(b[i:]))
+	i += 4
+	key := string(b[i : i+keyLen])
+	i += keyLen
+
+	nodeIDLen := int(binary.BigEndian.Uint32(b[i:]))
+	i += 4
+	nodeID := string(b[i : i+nodeIDLen])
+	i += nodeIDLen
+
+	seq := binary.BigEndian.Uint64(b[i:])
+	i += 8
+
+	tombstone := b[i] == 1
+	i++
+
+	valueLen := int(binary.BigEndian.Uint32(b[i:]))
+	i += 4
+	var value []byte
+	if valueLen > 0 {
+		value = make([]byte, valueLen)
+		copy(value, b[i:i+valueLen])
+	}
+	i += valueLen
+
+	return checkpointEntry{
+		key: key,
+		record: Record{
+			Value:     value,
+			Version:   Version{NodeID: nodeID, Seq: seq},
+			Tombstone: tombstone,
+		},
+	}, nil
+}
+
+// checkpointPath formats the on-disk filename for checkpoint seq.
+func checkpointPath(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%06d%s", checkpointFilePrefix, seq, checkpointFileSuffix))
+}
+
+// listCheckpoints returns the sequence numbers of every checkpoint file in
+// dir, sorted ascending.
+func listCheckpoints(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint: list %s: %w", dir, err)
+	}
+
+	var seqs []uint64
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if !strings.HasPrefix(name, checkpointFilePrefix) || !strings.HasSuffix(name, checkpointFileSuffix) {
+			continue
+		}
+
+		seqStr := strings.TrimSuffix(strings.TrimPrefix(name, checkpointFilePrefix), checkpointFileSuffix)
+		seq, err := strconv.ParseUint(seqStr, 10, 64)
+		if err != nil {
+			continue // not one of ours
+		}
+		seqs = append(seqs, seq)
+	}
+
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// Checkpointer periodically calls Engine.Checkpoint so the WAL doesn't grow
+// without bound. It fires on whichever of the op-count or timer threshold is
+// reached first; either can be left zero to disable that trigger. Run must
+// be running (as a goroutine) for either trigger to actually take a
+// checkpoint.
+type Checkpointer struct {
+	engine     *Engine
+	opInterval uint64
+	interval   time.Duration
+
+	applied uint64        // ops observed since the last checkpoint
+	trigger chan struct{} // signaled by the OnApply hook, drained by Run
+}
+
+// NewCheckpointer registers a Checkpointer against engine via OnApply, so it
+// starts counting ops immediately. The hook only signals trigger — it must
+// not call Engine.Checkpoint itself, since OnApply observers run while the
+// caller (Put/Delete/ApplyReplica) still holds e.mu, and Checkpoint takes
+// that same lock via Engine.Snapshot. Run is what actually checkpoints, on
+// its own goroutine outside the locked apply path.
+func NewCheckpointer(engine *Engine, opInterval uint64, interval time.Duration) *Checkpointer {
+	c := &Checkpointer{
+		engine:     engine,
+		opInterval: opInterval,
+		interval:   interval,
+		trigger:    make(chan struct{}, 1),
+	}
+
+	if opInterval > 0 {
+		engine.OnApply(func(Operation) {
+			if atomic.AddUint64(&c.applied, 1) >= opInterval {
+				atomic.StoreUint64(&c.applied, 0)
+				select {
+				case c.trigger <- struct{}{}:
+				default:
+					// A checkpoint is already pending; it'll cover this
+					// op too, so there's nothing more to signal.
+				}
+			}
+		})
+	}
+
+	return c
+}
+
+// Run drives both the op-count and timer triggers until ctx is cancelled,
+// actually calling Engine.Checkpoint from this goroutine rather than from
+// inside the OnApply hook. It must be started (as a goroutine) for the
+// op-count trigger registered in NewCheckpointer to have any effect; a
+// zero interval just means the timer side never fires.
+func (c *Checkpointer) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if c.interval > 0 {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			_ = c.engine.Checkpoint(ctx)
+		case <-c.trigger:
+			_ = c.engine.Checkpoint(ctx)
+		}
+	}
+}
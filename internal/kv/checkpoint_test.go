@@ -0,0 +1,121 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newTestEngine builds an Engine backed by a real FileWAL under t.TempDir(),
+// with checkpointing enabled at checkpointDir (empty disables it).
+func newTestEngine(t *testing.T, checkpointDir string) *Engine {
+	t.Helper()
+
+	wal, err := OpenWAL(t.TempDir(), WALConfig{})
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+
+	eng, err := NewEngine(EngineConfig{NodeID: "n1", CheckpointDir: checkpointDir}, NewStore(), wal, nil)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	return eng
+}
+
+// TestCheckpointerOpCountDoesNotDeadlock reproduces the regression where
+// NewCheckpointer's op-count trigger called Engine.Checkpoint synchronously
+// from inside the OnApply hook: Checkpoint takes e.mu via Engine.Snapshot,
+// but OnApply observers run while Put/Delete/ApplyReplica already hold it,
+// so the call would self-deadlock on the 3rd Put below. Run drives the
+// trigger from its own goroutine instead, so this must complete quickly.
+func TestCheckpointerOpCountDoesNotDeadlock(t *testing.T) {
+	eng := newTestEngine(t, t.TempDir())
+
+	checkpointer := NewCheckpointer(eng, 3, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go checkpointer.Run(ctx)
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 10; i++ {
+			if err := eng.Put(context.Background(), fmt.Sprintf("key-%d", i), []byte("v")); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Puts did not complete: Checkpointer's OnApply hook deadlocked the engine")
+	}
+}
+
+// TestEngineCheckpointAndReload verifies that Checkpoint captures live state,
+// including a tombstone, and that a fresh Engine pointed at the same
+// directories recovers that state from the checkpoint, skipping the WAL
+// records the checkpoint's watermark already covers.
+func TestEngineCheckpointAndReload(t *testing.T) {
+	walDir := t.TempDir()
+	checkpointDir := t.TempDir()
+
+	wal, err := OpenWAL(walDir, WALConfig{})
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+
+	eng, err := NewEngine(EngineConfig{NodeID: "n1", CheckpointDir: checkpointDir}, NewStore(), wal, nil)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if err := eng.Put(ctx, fmt.Sprintf("key-%d", i), []byte("v")); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	if err := eng.Delete(ctx, "key-2"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if err := eng.Checkpoint(ctx); err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if err := eng.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopenedWAL, err := OpenWAL(walDir, WALConfig{})
+	if err != nil {
+		t.Fatalf("reopen OpenWAL: %v", err)
+	}
+
+	reopened, err := NewEngine(EngineConfig{NodeID: "n1", CheckpointDir: checkpointDir}, NewStore(), reopenedWAL, nil)
+	if err != nil {
+		t.Fatalf("reopen NewEngine: %v", err)
+	}
+	defer reopened.Close()
+
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		value, ok := reopened.Get(ctx, key)
+		if i == 2 {
+			if ok {
+				t.Fatalf("%s: expected deleted key to stay deleted after reload, got %q", key, value)
+			}
+			continue
+		}
+		if !ok || string(value) != "v" {
+			t.Fatalf("%s: expected (%q, true) after reload, got (%q, %v)", key, "v", value, ok)
+		}
+	}
+}
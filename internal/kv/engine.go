@@ -15,6 +15,10 @@ type WAL interface {
 	Replay() ([]Operation, error)
 	Sync() error
 	Close() error
+
+	// Truncate drops whole segments fully covered by upTo, reclaiming
+	// disk space once a checkpoint has durably captured their contents.
+	Truncate(upTo Watermark) error
 }
 
 // Store is the in-memory state.
@@ -23,6 +27,17 @@ type Store interface {
 	Put(key string, record Record)
 	Delete(key string)
 	Scan(fn func(key string, record Record) bool)
+
+	// Snapshot returns a consistent point-in-time view that Put/Delete
+	// calls made after it returns will not be reflected in.
+	Snapshot() StoreSnapshot
+}
+
+// StoreSnapshot is a consistent, read-only point-in-time view of a Store.
+type StoreSnapshot interface {
+	Get(key string) (Record, bool)
+	Scan(fn func(key string, record Record) bool)
+	Range(start, end string, fn func(key string, record Record) bool)
 }
 
 // Replicator ships operations to other nodes.
@@ -36,6 +51,13 @@ type EngineConfig struct {
 	NodeID        string
 	SyncWrites    bool // fsync on every write
 	EnableReplica bool
+
+	// CheckpointDir, if set, enables checkpointing: NewEngine loads the
+	// newest valid checkpoint-<seq>.snap from this directory before replaying
+	// the WAL, and Engine.Checkpoint writes new ones here. Left empty,
+	// checkpointing is disabled and startup behaves exactly as before:
+	// a full WAL replay from the beginning.
+	CheckpointDir string
 }
 
 // Engine is the authoritative local state machine for a node. It includes engine config, store, wal, etc.
@@ -49,6 +71,11 @@ type Engine struct {
 	repl  Replicator
 
 	seq uint64 // monotonically increasing local sequence
+
+	onApply []func(Operation) // observers notified after every applied op
+	onClose []func() error    // run during Close, before the WAL is closed
+
+	checkpointSeq uint64 // sequence number of the last checkpoint file written or loaded
 }
 
 func NewEngine(
@@ -65,21 +92,67 @@ func NewEngine(
 		repl:  repl,
 	}
 
-	// replay WAL to rebuild in-memory state
+	watermark, err := e.loadCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	e.seq = watermark[cfg.NodeID]
+
+	// replay the WAL to rebuild state the checkpoint didn't already
+	// capture; anything a record's origin node already has covered by
+	// watermark was folded into the checkpoint and would just be a
+	// redundant (harmless, but wasted) Put/Delete here.
 	ops, err := wal.Replay()
 	if err != nil {
 		return nil, err
 	}
 
 	for _, op := range ops {
+		if op.Version.Seq <= watermark[op.Version.NodeID] {
+			continue
+		}
 		e.applyNoWal(op)
 	}
 
 	return e, nil
 }
 
+// OnApply registers an observer invoked synchronously, in registration
+// order, after every operation is applied to the store. Ops replayed from the
+// WAL (or loaded from a checkpoint) during NewEngine are applied before any
+// observer can be registered, so observers never see them. Subsystems that maintain derived
+// state incrementally (e.g. the antientropy Merkle tree) hook in here
+// rather than re-deriving state from Export on every change. Register
+// observers before the Engine is serving concurrent traffic; they run
+// under the same lock as the mutation they observe and must not call back
+// into the Engine.
+func (e *Engine) OnApply(fn func(Operation)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onApply = append(e.onApply, fn)
+}
+
+// OnClose registers fn to run during Close, before the WAL is closed, so a
+// subsystem like hints (whose drain workers must stop and whose queues
+// must flush before the process exits) shuts down cleanly alongside the
+// Engine rather than needing its own separate lifecycle management.
+func (e *Engine) OnClose(fn func() error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onClose = append(e.onClose, fn)
+}
+
 func (e *Engine) Close() error {
-	return e.wal.Close()
+	var firstErr error
+	for _, fn := range e.onClose {
+		if err := fn(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := e.wal.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
 }
 
 // API stuff
@@ -151,7 +224,7 @@ func (e *Engine) ApplyReplica(ctx context.Context, op Operation) error {
 	defer e.mu.Unlock()
 
 	rec, ok := e.store.Get(op.Key)
-	if ok && rec.Version >= op.Version {
+	if ok && !op.Version.GreaterThan(rec.Version) {
 		// stale or duplicate
 		return nil
 	}
@@ -165,11 +238,22 @@ func (e *Engine) ApplyReplica(ctx context.Context, op Operation) error {
 }
 
 // migration and rebalancing
+
+// Export streams a consistent snapshot of local state rather than racing a
+// live scan against concurrent writers, so a migration/rebalance observes
+// one point-in-time view of the keyspace.
 func (e *Engine) Export(fn func(key string, record Record) bool) {
+	e.Snapshot(context.Background()).Scan(fn)
+}
+
+// Snapshot returns a consistent point-in-time view of the Engine's state,
+// for anti-entropy, backup, or other readers that need a stable scan
+// without blocking concurrent Put/Delete.
+func (e *Engine) Snapshot(ctx context.Context) StoreSnapshot {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	e.store.Scan(fn)
+	return e.store.Snapshot()
 }
 
 func (e *Engine) Import(op Operation) error {
@@ -207,6 +291,10 @@ func (e *Engine) applyNoWal(op Operation) {
 	default:
 		panic("unknown op type")
 	}
+
+	for _, fn := range e.onApply {
+		fn(op)
+	}
 }
 
 var ErrNotFound = errors.New("key not found")
@@ -2,12 +2,19 @@ package kv
 
 import "sync"
 
+// MemStore is the original map-backed Store: a single mutable map guarded
+// by an RWMutex. It's kept around (and exported via NewMapStore) for tests
+// that want a simple reference implementation; NewStore returns the MVCC
+// variant by default. Snapshot takes a point-in-time copy of the map, while
+// Scan iterates the live map under the read lock; neither lets a caller
+// observe a concurrent Put mid-scan.
 type MemStore struct {
 	mu   sync.RWMutex
 	data map[string]Record
 }
 
-func NewStore() Store {
+// NewMapStore constructs the map-backed Store implementation.
+func NewMapStore() Store {
 	return &MemStore{
 		data: make(map[string]Record),
 	}
@@ -58,3 +65,48 @@ func (s *MemStore) Scan(fn func(key string, record Record) bool) {
 		}
 	}
 }
+
+// Snapshot returns a point-in-time copy of the map so a long Scan/Range
+// doesn't hold mu for its whole duration. Unlike MVCCStore this still pays
+// a full copy per snapshot; it exists for parity with the Store interface,
+// not for the lock-free guarantees MVCCStore provides.
+func (s *MemStore) Snapshot() StoreSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data := make(map[string]Record, len(s.data))
+	for k, v := range s.data {
+		data[k] = v
+	}
+	return mapSnapshot(data)
+}
+
+// mapSnapshot is the StoreSnapshot returned by MemStore.Snapshot.
+type mapSnapshot map[string]Record
+
+func (m mapSnapshot) Get(key string) (Record, bool) {
+	rec, ok := m[key]
+	if !ok || rec.IsDeleted() {
+		return Record{}, false
+	}
+	return rec, true
+}
+
+func (m mapSnapshot) Scan(fn func(key string, record Record) bool) {
+	for k, v := range m {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+func (m mapSnapshot) Range(start, end string, fn func(key string, record Record) bool) {
+	for k, v := range m {
+		if k < start || (end != "" && k >= end) {
+			continue
+		}
+		if !fn(k, v) {
+			return
+		}
+	}
+}
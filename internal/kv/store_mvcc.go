@@ -0,0 +1,222 @@
+package kv
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// MVCCStore is a copy-on-write, ordered Store modeled on the memdb-style
+// "immutable radix tree" approach used by state-store libraries: writers
+// build a new tree rooted at a fresh node and publish it atomically, while
+// readers dereference a root once and walk it without ever taking a lock.
+// A consistent-point-in-time Scan/Range is therefore just "hold the root
+// you already loaded" rather than something that contends with writers.
+//
+// The tree itself is a treap (a binary search tree ordered by key, heap-
+// ordered by a per-key priority) because it gives persistence "for free":
+// inserting along a path only ever allocates nodes on that path, and the
+// standard treap rotations can be performed on those freshly-allocated
+// nodes in place without mutating anything reachable from an older root.
+type MVCCStore struct {
+	mu   sync.Mutex   // serializes writers while they build the next root
+	root atomic.Value // holds mvccRootHolder
+}
+
+// mvccRootHolder wraps *mvccNode so atomic.Value (which rejects storing a
+// nil interface) can represent an empty tree.
+type mvccRootHolder struct {
+	node *mvccNode
+}
+
+type mvccNode struct {
+	key      string
+	record   Record
+	priority uint32
+	left     *mvccNode
+	right    *mvccNode
+}
+
+// NewStore constructs the MVCC Store implementation. This is the default
+// Store for production use; NewMapStore remains available for tests that
+// want the simpler map-backed reference implementation.
+func NewStore() Store {
+	s := &MVCCStore{}
+	s.root.Store(mvccRootHolder{})
+	return s
+}
+
+func (s *MVCCStore) loadRoot() *mvccNode {
+	return s.root.Load().(mvccRootHolder).node
+}
+
+func (s *MVCCStore) Get(key string) (Record, bool) {
+	return mvccGet(s.loadRoot(), key)
+}
+
+func (s *MVCCStore) Put(key string, record Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root := s.loadRoot()
+
+	if existing, ok := mvccLookup(root, key); ok && !record.Version.GreaterThan(existing.Version) {
+		return
+	}
+
+	newRoot := mvccInsert(root, key, record)
+	s.root.Store(mvccRootHolder{node: newRoot})
+}
+
+func (s *MVCCStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root := s.loadRoot()
+
+	existing, ok := mvccLookup(root, key)
+	if !ok {
+		return
+	}
+
+	existing.Tombstone = true
+	newRoot := mvccInsert(root, key, existing)
+	s.root.Store(mvccRootHolder{node: newRoot})
+}
+
+func (s *MVCCStore) Scan(fn func(key string, record Record) bool) {
+	mvccScan(s.loadRoot(), "", "", fn)
+}
+
+// Snapshot captures the current root. Because every node reachable from it
+// is immutable, the returned StoreSnapshot is a stable point-in-time view
+// even while writers keep publishing new roots concurrently.
+func (s *MVCCStore) Snapshot() StoreSnapshot {
+	return mvccSnapshot{root: s.loadRoot()}
+}
+
+// mvccSnapshot is the StoreSnapshot returned by MVCCStore.Snapshot.
+type mvccSnapshot struct {
+	root *mvccNode
+}
+
+func (snap mvccSnapshot) Get(key string) (Record, bool) {
+	return mvccGet(snap.root, key)
+}
+
+func (snap mvccSnapshot) Scan(fn func(key string, record Record) bool) {
+	mvccScan(snap.root, "", "", fn)
+}
+
+func (snap mvccSnapshot) Range(start, end string, fn func(key string, record Record) bool) {
+	mvccScan(snap.root, start, end, fn)
+}
+
+func mvccGet(n *mvccNode, key string) (Record, bool) {
+	rec, ok := mvccLookup(n, key)
+	if !ok || rec.IsDeleted() {
+		return Record{}, false
+	}
+	return rec, true
+}
+
+// mvccLookup finds key without filtering tombstones, so callers that need
+// the existing record for a version check (Put, Delete) still see it.
+func mvccLookup(n *mvccNode, key string) (Record, bool) {
+	for n != nil {
+		switch {
+		case key < n.key:
+			n = n.left
+		case key > n.key:
+			n = n.right
+		default:
+			return n.record, true
+		}
+	}
+	return Record{}, false
+}
+
+// mvccInsert returns a new tree with (key, record) set, copying only the
+// nodes along the path to key and re-establishing heap order with
+// rotations on those freshly-allocated nodes.
+func mvccInsert(n *mvccNode, key string, record Record) *mvccNode {
+	if n == nil {
+		return &mvccNode{key: key, record: record, priority: mvccPriority(key)}
+	}
+
+	switch {
+	case key < n.key:
+		left := mvccInsert(n.left, key, record)
+		next := &mvccNode{key: n.key, record: n.record, priority: n.priority, left: left, right: n.right}
+		if left.priority > next.priority {
+			next = mvccRotateRight(next)
+		}
+		return next
+
+	case key > n.key:
+		right := mvccInsert(n.right, key, record)
+		next := &mvccNode{key: n.key, record: n.record, priority: n.priority, left: n.left, right: right}
+		if right.priority > next.priority {
+			next = mvccRotateLeft(next)
+		}
+		return next
+
+	default:
+		return &mvccNode{key: n.key, record: record, priority: n.priority, left: n.left, right: n.right}
+	}
+}
+
+// mvccRotateRight and mvccRotateLeft are the standard treap rotations.
+// They're only ever called on n (and n.left/n.right) that mvccInsert just
+// allocated fresh for this call, so mutating those pointers in place never
+// disturbs a root any concurrent reader may be holding.
+func mvccRotateRight(n *mvccNode) *mvccNode {
+	l := n.left
+	n.left = l.right
+	l.right = n
+	return l
+}
+
+func mvccRotateLeft(n *mvccNode) *mvccNode {
+	r := n.right
+	n.right = r.left
+	r.left = n
+	return r
+}
+
+// mvccPriority derives a treap priority from the key. It only needs to be
+// roughly uniform, not cryptographically random, to keep the tree shallow.
+func mvccPriority(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// mvccScan walks n in key order, restricting to [start, end) when either
+// bound is non-empty (an empty start/end means "unbounded" on that side).
+func mvccScan(n *mvccNode, start, end string, fn func(key string, record Record) bool) bool {
+	if n == nil {
+		return true
+	}
+
+	if start == "" || n.key >= start {
+		if !mvccScan(n.left, start, end, fn) {
+			return false
+		}
+	}
+
+	inRange := (start == "" || n.key >= start) && (end == "" || n.key < end)
+	if inRange {
+		if !fn(n.key, n.record) {
+			return false
+		}
+	}
+
+	if end == "" || n.key < end {
+		if !mvccScan(n.right, start, end, fn) {
+			return false
+		}
+	}
+
+	return true
+}
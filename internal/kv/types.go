@@ -57,14 +57,12 @@ type Operation struct {
 
 // IsPut returns true if this operation is a PUT.
 func (op Operation) IsPut() bool {
-	// TODO: Return true when op.Type indicates a put.
-	return false
+	return op.Type == OpPut
 }
 
 // IsDelete returns true if this operation is a DELETE.
 func (op Operation) IsDelete() bool {
-	// TODO: Return true when op.Type indicates a delete.
-	return false
+	return op.Type == OpDelete
 }
 
 
@@ -79,12 +77,5 @@ type Record struct {
 
 // IsDeleted returns true if this record represents a deleted key.
 func (r Record) IsDeleted() bool {
-	// TODO: Decide what condition denotes a deletion.
-	
-	// Think about:
-	// - Should this depend only on Tombstone?
-	// - Should Value be ignored when deleted?
-	return false
-}
-
-// Invariants??
\ No newline at end of file
+	return r.Tombstone
+}
\ No newline at end of file
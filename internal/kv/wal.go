@@ -2,42 +2,464 @@ package kv
 
 import (
 	"bufio"
-	"encoding/json"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 )
 
-// FileWAL is an append-only write-ahead log stored on disk.
+const (
+	walSegmentPrefix = "wal-"
+	walSegmentSuffix = ".log"
+
+	// defaultMaxSegmentBytes is used when WALConfig.MaxSegmentBytes is unset.
+	defaultMaxSegmentBytes = 64 * 1024 * 1024
+
+	// opEncodingVersion tags the binary layout of an encoded Operation so a
+	// future change to the framing can be detected during Replay instead of
+	// silently misparsing older segments.
+	opEncodingVersion byte = 1
+)
+
+// crc32cTable is the Castagnoli polynomial table, matching the "crc32c"
+// framing described on FileWAL.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WALConfig controls segment rotation for a FileWAL.
+type WALConfig struct {
+	// MaxSegmentBytes is the size at which the active segment is rotated
+	// into a new one. Zero selects defaultMaxSegmentBytes.
+	MaxSegmentBytes int64
+}
+
+// FileWAL is a segmented, append-only write-ahead log stored on disk.
+//
+// Every record is framed as:
+//
+//	uint32 length | uint32 crc32c(payload) | payload
+//
+// where length counts only the payload bytes and payload is a versioned
+// binary encoding of an Operation (see encodeOperation). The log is split
+// across segment files named "wal-000001.log", "wal-000002.log", ... so
+// that Truncate can drop whole segments once a checkpoint covers them.
 type FileWAL struct {
-	mu   sync.Mutex
-	file *os.File
-	buf  *bufio.Writer
+	mu  sync.Mutex
+	dir string
+	cfg WALConfig
+
+	file    *os.File
+	buf     *bufio.Writer
+	segSeq  uint64 // sequence number of the active segment
+	segSize int64  // bytes written to the active segment so far
 }
 
-// OpenWAL opens (or creates) a WAL at the given path.
-func OpenWAL(path string) (*FileWAL, error) {
-	// Open file with os.OpenFile
-	//    - Create if not exists
-	//    - Append mode
-	//    - Read + write
-	// Create a bufio.Writer
-	// Return &FileWAL?
+// OpenWAL opens (or creates) a segmented WAL rooted at dir, resuming the
+// highest-numbered existing segment (or starting segment 1 if dir is empty).
+func OpenWAL(dir string, cfg WALConfig) (*FileWAL, error) {
+	if cfg.MaxSegmentBytes <= 0 {
+		cfg.MaxSegmentBytes = defaultMaxSegmentBytes
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("wal: create dir %s: %w", dir, err)
+	}
+
+	segs, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seq := uint64(1)
+	if len(segs) > 0 {
+		seq = segs[len(segs)-1]
+	}
+
+	w := &FileWAL{
+		dir: dir,
+		cfg: cfg,
+	}
 
-	return nil, nil
+	if err := w.openSegment(seq); err != nil {
+		return nil, err
+	}
+
+	return w, nil
 }
 
+// openSegment opens (creating if necessary) the segment with the given
+// sequence number as the active write target, in append mode so that
+// Replay's corrective os.Truncate of a torn tail is immediately reflected
+// in subsequent writes.
+func (w *FileWAL) openSegment(seq uint64) error {
+	path := filepath.Join(w.dir, segmentName(seq))
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("wal: open segment %s: %w", path, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("wal: stat segment %s: %w", path, err)
+	}
+
+	w.file = f
+	w.buf = bufio.NewWriter(f)
+	w.segSeq = seq
+	w.segSize = info.Size()
+	return nil
+}
 
+// rotate flushes and closes the active segment and opens the next one.
+func (w *FileWAL) rotate() error {
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("wal: flush before rotate: %w", err)
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("wal: close before rotate: %w", err)
+	}
+
+	return w.openSegment(w.segSeq + 1)
+}
+
+// Append writes op as a single CRC-checked record, rotating to a new
+// segment first if the active one has grown past MaxSegmentBytes.
 func (w *FileWAL) Append(op Operation) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	payload := encodeOperation(op)
+	frame := frameRecord(payload)
+
+	if w.segSize > 0 && w.segSize+int64(len(frame)) > w.cfg.MaxSegmentBytes {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.buf.Write(frame)
+	if err != nil {
+		return fmt.Errorf("wal: append: %w", err)
+	}
+	w.segSize += int64(n)
 	return nil
 }
 
-
+// Sync flushes buffered writes and fsyncs the active segment, giving
+// fdatasync-style durability for everything Appended so far.
 func (w *FileWAL) Sync() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("wal: flush: %w", err)
+	}
+	return w.file.Sync()
+}
+
+// Close flushes and closes the active segment.
+func (w *FileWAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("wal: flush on close: %w", err)
+	}
+	return w.file.Close()
+}
+
+// Replay reads every segment in order and returns the operations recorded
+// so far. A short read or CRC mismatch is treated as a torn write left by a
+// crash mid-append: the offending segment is truncated to the last valid
+// record boundary and replay stops there, returning everything read so far
+// without error. Replay must be called before any Append, since the active
+// segment's on-disk size may change underneath w.segSize.
+func (w *FileWAL) Replay() ([]Operation, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	segs, err := listSegments(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []Operation
+
+	for _, seq := range segs {
+		path := filepath.Join(w.dir, segmentName(seq))
+
+		segOps, validSize, torn, err := replaySegment(path)
+		if err != nil {
+			return nil, err
+		}
+
+		ops = append(ops, segOps...)
+
+		if torn {
+			if err := os.Truncate(path, validSize); err != nil {
+				return nil, fmt.Errorf("wal: truncate torn segment %s: %w", path, err)
+			}
+			if seq == w.segSeq {
+				w.segSize = validSize
+			}
+			break
+		}
+	}
+
+	return ops, nil
+}
+
+// Watermark records, per origin NodeID, the highest Seq a checkpoint has
+// durably captured. A WAL record is fully covered by a checkpoint once its
+// own Version.Seq is no greater than Watermark[Version.NodeID].
+type Watermark map[string]uint64
+
+// Truncate drops whole segments whose every record is covered by upTo, so
+// a checkpointer can reclaim disk space for state it has already durably
+// captured. The active segment is never removed.
+func (w *FileWAL) Truncate(upTo Watermark) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	segs, err := listSegments(w.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, seq := range segs {
+		if seq == w.segSeq {
+			continue // never remove the active segment
+		}
+
+		path := filepath.Join(w.dir, segmentName(seq))
+
+		covered, err := segmentCoveredBy(path, upTo)
+		if err != nil {
+			return err
+		}
+		if !covered {
+			// Segments are chronological and Watermark only grows over
+			// time, so in practice once one segment isn't fully covered
+			// neither are the ones after it; stop here rather than
+			// re-scanning every later segment on every checkpoint.
+			break
+		}
+
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("wal: remove covered segment %s: %w", path, err)
+		}
+	}
+
 	return nil
 }
+
+// segmentCoveredBy reports whether every record in the segment at path is
+// covered by upTo, i.e. the segment is fully superseded by a checkpoint.
+func segmentCoveredBy(path string, upTo Watermark) (bool, error) {
+	ops, _, _, err := replaySegment(path)
+	if err != nil {
+		return false, err
+	}
+
+	for _, op := range ops {
+		if op.Version.Seq > upTo[op.Version.NodeID] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// replaySegment reads every valid record from the segment at path. validSize
+// is the byte offset of the end of the last valid record (i.e. where the
+// file should be truncated to if torn is true).
+func replaySegment(path string) (ops []Operation, validSize int64, torn bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("wal: open segment %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	var offset int64
+	header := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return ops, offset, false, nil
+			}
+			// partial header: torn write.
+			return ops, offset, true, nil
+		}
+
+		length := binary.BigEndian.Uint32(header[0:4])
+		wantCRC := binary.BigEndian.Uint32(header[4:8])
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			// partial payload: torn write.
+			return ops, offset, true, nil
+		}
+
+		if crc32.Checksum(payload, crc32cTable) != wantCRC {
+			return ops, offset, true, nil
+		}
+
+		op, err := decodeOperation(payload)
+		if err != nil {
+			return ops, offset, true, nil
+		}
+
+		ops = append(ops, op)
+		offset += int64(8 + len(payload))
+	}
+}
+
+// frameRecord wraps payload in the on-disk "length | crc32c | payload" frame.
+func frameRecord(payload []byte) []byte {
+	frame := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crc32cTable))
+	copy(frame[8:], payload)
+	return frame
+}
+
+// encodeOperation produces the versioned binary payload for op:
+//
+//	byte    opEncodingVersion
+//	byte    op.Type
+//	uint32  len(Version.NodeID) | bytes
+//	uint64  Version.Seq
+//	uint32  len(Key) | bytes
+//	uint32  len(Value) | bytes (0 for deletes)
+func encodeOperation(op Operation) []byte {
+	nodeID := []byte(op.Version.NodeID)
+	key := []byte(op.Key)
+	value := op.Value
+
+	size := 1 + 1 + 4 + len(nodeID) + 8 + 4 + len(key) + 4 + len(value)
+	buf := make([]byte, size)
+
+	i := 0
+	buf[i] = opEncodingVersion
+	i++
+	buf[i] = byte(op.Type)
+	i++
+
+	binary.BigEndian.PutUint32(buf[i:], uint32(len(nodeID)))
+	i += 4
+	i += copy(buf[i:], nodeID)
+
+	binary.BigEndian.PutUint64(buf[i:], op.Version.Seq)
+	i += 8
+
+	binary.BigEndian.PutUint32(buf[i:], uint32(len(key)))
+	i += 4
+	i += copy(buf[i:], key)
+
+	binary.BigEndian.PutUint32(buf[i:], uint32(len(value)))
+	i += 4
+	i += copy(buf[i:], value)
+
+	return buf[:i]
+}
+
+// decodeOperation is the inverse of encodeOperation. It returns an error on
+// any malformed or truncated payload so the caller can treat it as torn
+// write corruption rather than panicking on an out-of-range slice.
+func decodeOperation(b []byte) (op Operation, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("wal: malformed operation record: %v", r)
+		}
+	}()
+
+	i := 0
+	version := b[i]
+	i++
+	if version != opEncodingVersion {
+		return Operation{}, fmt.Errorf("wal: unsupported operation encoding version %d", version)
+	}
+
+	opType := OpType(b[i])
+	i++
+
+	nodeIDLen := int(binary.BigEndian.Uint32(b[i:]))
+	i += 4
+	nodeID := string(b[i : i+nodeIDLen])
+	i += nodeIDLen
+
+	seq := binary.BigEndian.Uint64(b[i:])
+	i += 8
+
+	keyLen := int(binary.BigEndian.Uint32(b[i:]))
+	i += 4
+	key := string(b[i : i+keyLen])
+	i += keyLen
+
+	valueLen := int(binary.BigEndian.Uint32(b[i:]))
+	i += 4
+	var value []byte
+	if valueLen > 0 {
+		value = make([]byte, valueLen)
+		copy(value, b[i:i+valueLen])
+	}
+	i += valueLen
+
+	op = Operation{
+		Type: opType,
+		Key:  key,
+		Version: Version{
+			NodeID: nodeID,
+			Seq:    seq,
+		},
+	}
+	if opType == OpPut {
+		op.Value = value
+	}
+
+	return op, nil
+}
+
+// segmentName formats the on-disk filename for segment seq.
+func segmentName(seq uint64) string {
+	return fmt.Sprintf("%s%06d%s", walSegmentPrefix, seq, walSegmentSuffix)
+}
+
+// listSegments returns the sequence numbers of every segment file in dir,
+// sorted ascending.
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("wal: list segments: %w", err)
+	}
+
+	var segs []uint64
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if !strings.HasPrefix(name, walSegmentPrefix) || !strings.HasSuffix(name, walSegmentSuffix) {
+			continue
+		}
+
+		seqStr := strings.TrimSuffix(strings.TrimPrefix(name, walSegmentPrefix), walSegmentSuffix)
+		seq, err := strconv.ParseUint(seqStr, 10, 64)
+		if err != nil {
+			continue // not one of ours
+		}
+		segs = append(segs, seq)
+	}
+
+	sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+	return segs, nil
+}
@@ -0,0 +1,222 @@
+package rebalance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/taitelee/kvstore/internal/hashing"
+	"github.com/taitelee/kvstore/internal/kv"
+)
+
+// defaultBatchSize bounds how many ops a single StreamImport call carries,
+// so a large range is streamed incrementally rather than buffered whole.
+const defaultBatchSize = 256
+
+// Transport is how a Handoff ships a changed range's ops to its new owner.
+type Transport interface {
+	// StreamImport sends ops, in order, for target to apply. The receiver
+	// is expected to apply each one through Engine.ApplyReplica, whose
+	// version check means arrival order (and re-delivery after a retry)
+	// doesn't affect correctness.
+	StreamImport(ctx context.Context, target hashing.NodeID, ops []kv.Operation) error
+
+	// CommitRange tells target the handoff for rng is complete. A nil
+	// error means target has durably recorded that it now owns rng, which
+	// is the only signal that makes it safe for the source to drop its
+	// own copy of the range.
+	CommitRange(ctx context.Context, target hashing.NodeID, rng Range) error
+}
+
+// Handoff drives one node's side of streaming key-range handoff. Progress
+// is persisted to disk so a crashed handoff doesn't re-ship ranges the
+// destination already committed; a range that was only partially streamed
+// before a crash is simply redone from scratch, which is safe because the
+// destination applies every op through the same idempotent, version-checked
+// path as anti-entropy and hinted handoff.
+type Handoff struct {
+	transport Transport
+	progress  *progress
+}
+
+// Open opens (or creates) a Handoff whose progress is persisted under dir.
+func Open(dir string, transport Transport) (*Handoff, error) {
+	p, err := openProgress(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handoff{transport: transport, progress: p}, nil
+}
+
+// Run streams every plan entry not already committed, in the order given,
+// then commits and persists it before moving to the next one.
+func (h *Handoff) Run(ctx context.Context, snap kv.StoreSnapshot, plan []RangeHandoff) error {
+	for _, rh := range plan {
+		if h.progress.isCommitted(rh.Range) {
+			continue
+		}
+
+		if err := h.stream(ctx, snap, rh); err != nil {
+			return err
+		}
+
+		if err := h.transport.CommitRange(ctx, rh.Target, rh.Range); err != nil {
+			return fmt.Errorf("rebalance: commit range %s to %s: %w", rh.Range.ID(), rh.Target, err)
+		}
+
+		if err := h.progress.markCommitted(rh.Range); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stream scans snap for keys in rh.Range and ships them to rh.Target in
+// batches of defaultBatchSize.
+func (h *Handoff) stream(ctx context.Context, snap kv.StoreSnapshot, rh RangeHandoff) error {
+	var (
+		batch   []kv.Operation
+		scanErr error
+	)
+
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		if err := h.transport.StreamImport(ctx, rh.Target, batch); err != nil {
+			scanErr = err
+			return false
+		}
+		batch = batch[:0]
+		return true
+	}
+
+	snap.Scan(func(key string, record kv.Record) bool {
+		if !rh.Range.Contains(hashing.HashKey(key)) {
+			return true
+		}
+
+		batch = append(batch, operationFor(key, record))
+		if len(batch) < defaultBatchSize {
+			return true
+		}
+		return flush()
+	})
+
+	if scanErr == nil {
+		flush()
+	}
+	if scanErr != nil {
+		return fmt.Errorf("rebalance: stream range %s to %s: %w", rh.Range.ID(), rh.Target, scanErr)
+	}
+
+	return nil
+}
+
+// Committed reports whether rng has already been handed off and committed
+// — the condition under which the caller may safely drop its own copy of
+// the range's keys.
+func (h *Handoff) Committed(rng Range) bool {
+	return h.progress.isCommitted(rng)
+}
+
+// operationFor reconstructs the Operation that produced record, the way
+// Engine.ApplyReplica expects to receive it.
+func operationFor(key string, record kv.Record) kv.Operation {
+	op := kv.Operation{Key: key, Version: record.Version}
+	if record.Tombstone {
+		op.Type = kv.OpDelete
+	} else {
+		op.Type = kv.OpPut
+		op.Value = record.Value
+	}
+	return op
+}
+
+// progressFileName is the on-disk name of a Handoff's progress file, one
+// per directory.
+const progressFileName = "rebalance.progress"
+
+// progress persists the set of ranges whose handoff has committed.
+type progress struct {
+	mu        sync.Mutex
+	path      string
+	committed map[string]struct{}
+}
+
+func openProgress(dir string) (*progress, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("rebalance: create dir %s: %w", dir, err)
+	}
+
+	p := &progress{
+		path:      filepath.Join(dir, progressFileName),
+		committed: make(map[string]struct{}),
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p, nil
+		}
+		return nil, fmt.Errorf("rebalance: read progress file %s: %w", p.path, err)
+	}
+
+	for _, id := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if id == "" {
+			continue
+		}
+		p.committed[id] = struct{}{}
+	}
+
+	return p, nil
+}
+
+func (p *progress) isCommitted(rng Range) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, ok := p.committed[rng.ID()]
+	return ok
+}
+
+func (p *progress) markCommitted(rng Range) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.committed[rng.ID()] = struct{}{}
+	return p.rewriteLocked()
+}
+
+// rewriteLocked atomically rewrites the progress file: written to a temp
+// file and renamed into place, the same crash-safe pattern the hints queue
+// uses for its own on-disk compaction.
+func (p *progress) rewriteLocked() error {
+	ids := make([]string, 0, len(p.committed))
+	for id := range p.committed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var buf strings.Builder
+	for _, id := range ids {
+		buf.WriteString(id)
+		buf.WriteByte('\n')
+	}
+
+	tmpPath := p.path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(buf.String()), 0o644); err != nil {
+		return fmt.Errorf("rebalance: write progress temp file: %w", err)
+	}
+	if err := os.Rename(tmpPath, p.path); err != nil {
+		return fmt.Errorf("rebalance: rename progress file: %w", err)
+	}
+
+	return nil
+}
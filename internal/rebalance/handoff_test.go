@@ -0,0 +1,134 @@
+package rebalance
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/taitelee/kvstore/internal/hashing"
+	"github.com/taitelee/kvstore/internal/kv"
+)
+
+// fakeTransport records every StreamImport/CommitRange call in memory, and
+// can be told to fail the next StreamImport to exercise crash-resume.
+type fakeTransport struct {
+	imported       []kv.Operation
+	committed      []Range
+	failNextImport bool
+}
+
+func (f *fakeTransport) StreamImport(ctx context.Context, target hashing.NodeID, ops []kv.Operation) error {
+	if f.failNextImport {
+		f.failNextImport = false
+		return errors.New("simulated transport failure")
+	}
+	f.imported = append(f.imported, ops...)
+	return nil
+}
+
+func (f *fakeTransport) CommitRange(ctx context.Context, target hashing.NodeID, rng Range) error {
+	f.committed = append(f.committed, rng)
+	return nil
+}
+
+func newTestSnapshot(t *testing.T, keys ...string) kv.StoreSnapshot {
+	t.Helper()
+	store := kv.NewStore()
+	for i, k := range keys {
+		store.Put(k, kv.Record{Value: []byte(k), Version: kv.Version{NodeID: "n1", Seq: uint64(i + 1)}})
+	}
+	return store.Snapshot()
+}
+
+// TestHandoffRunStreamsAndCommits checks the happy path: every key in the
+// handed-off range is streamed and the range is committed exactly once.
+func TestHandoffRunStreamsAndCommits(t *testing.T) {
+	ctx := context.Background()
+	transport := &fakeTransport{}
+
+	h, err := Open(t.TempDir(), transport)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	rng := Range{Low: 0, High: ^uint32(0)} // whole keyspace
+	snap := newTestSnapshot(t, "a", "b", "c")
+
+	plan := []RangeHandoff{{Range: rng, Target: "B"}}
+	if err := h.Run(ctx, snap, plan); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(transport.imported) != 3 {
+		t.Fatalf("expected 3 ops streamed, got %d", len(transport.imported))
+	}
+	if len(transport.committed) != 1 || transport.committed[0] != rng {
+		t.Fatalf("expected range %s committed once, got %v", rng.ID(), transport.committed)
+	}
+	if !h.Committed(rng) {
+		t.Fatalf("expected Handoff.Committed to report the range as committed")
+	}
+}
+
+// TestHandoffRunSkipsAlreadyCommitted checks that re-running Run against
+// progress persisted from a prior run (simulating a process restart after
+// the handoff committed) does not re-stream a range that already committed.
+func TestHandoffRunSkipsAlreadyCommitted(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	rng := Range{Low: 0, High: ^uint32(0)}
+	plan := []RangeHandoff{{Range: rng, Target: "B"}}
+	snap := newTestSnapshot(t, "a")
+
+	transport := &fakeTransport{}
+	h, err := Open(dir, transport)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := h.Run(ctx, snap, plan); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// Reopen against the same progress directory, simulating a restart.
+	reopened, err := Open(dir, transport)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if err := reopened.Run(ctx, snap, plan); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+
+	if len(transport.committed) != 1 {
+		t.Fatalf("expected CommitRange called once across both runs, got %d", len(transport.committed))
+	}
+}
+
+// TestHandoffRunLeavesRangeUncommittedOnStreamFailure checks that a
+// StreamImport failure prevents CommitRange from being called and leaves
+// the range uncommitted, so a retry will redo the whole range from scratch
+// rather than silently losing it.
+func TestHandoffRunLeavesRangeUncommittedOnStreamFailure(t *testing.T) {
+	ctx := context.Background()
+	transport := &fakeTransport{failNextImport: true}
+
+	h, err := Open(t.TempDir(), transport)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	rng := Range{Low: 0, High: ^uint32(0)}
+	snap := newTestSnapshot(t, "a")
+	plan := []RangeHandoff{{Range: rng, Target: "B"}}
+
+	if err := h.Run(ctx, snap, plan); err == nil {
+		t.Fatalf("expected Run to propagate the simulated transport failure")
+	}
+
+	if h.Committed(rng) {
+		t.Fatalf("range must not be marked committed when streaming failed")
+	}
+	if len(transport.committed) != 0 {
+		t.Fatalf("CommitRange must not be called after a streaming failure")
+	}
+}
@@ -0,0 +1,147 @@
+// Package rebalance streams key ranges between nodes when the hash ring's
+// topology changes (AddNode/RemoveNode), instead of requiring a full
+// Engine.Export of everything. It diffs the old and new hashing.Ring to
+// find the hash ranges whose ownership actually moved, streams only the
+// keys in those ranges to their new owner, and only lets the caller drop
+// its local copy once the destination has acked the handoff.
+package rebalance
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/taitelee/kvstore/internal/hashing"
+)
+
+// Range is a hash interval, open on the low end and closed on the high end:
+// (Low, High]. It wraps around zero when Low > High, covering (Low, max
+// uint32] union [0, High] — the same convention hashing.Ring's sort.Search
+// predicate (hash >= boundary) implies for the range owned by a boundary.
+type Range struct {
+	Low  uint32
+	High uint32
+}
+
+// Contains reports whether hash falls in r.
+func (r Range) Contains(hash uint32) bool {
+	if r.Low < r.High {
+		return hash > r.Low && hash <= r.High
+	}
+	// wraps around zero
+	return hash > r.Low || hash <= r.High
+}
+
+// ID is a stable identifier for r, used as the key in a persisted Progress
+// file.
+func (r Range) ID() string {
+	return formatRangeID(r.Low, r.High)
+}
+
+// ChangedRanges partitions the hash ring into the maximal ranges that map
+// to a fixed replica set on either old or new, and returns the ones whose
+// replica set actually differs between the two. n is the replica count
+// (SelectorConfig.N / ReplicationFactor) used to resolve each range's
+// owners.
+func ChangedRanges(old, new hashing.Ring, n int) []Range {
+	bounds := mergeBoundaries(old.Boundaries(), new.Boundaries())
+	if len(bounds) == 0 {
+		return nil
+	}
+
+	var changed []Range
+	for i, high := range bounds {
+		low := bounds[(i-1+len(bounds))%len(bounds)]
+
+		oldOwners := old.ReplicasAt(high, n)
+		newOwners := new.ReplicasAt(high, n)
+		if sameOwners(oldOwners, newOwners) {
+			continue
+		}
+
+		changed = append(changed, Range{Low: low, High: high})
+	}
+
+	return changed
+}
+
+// RangeHandoff is one changed range paired with the node that should
+// receive it.
+type RangeHandoff struct {
+	Range  Range
+	Target hashing.NodeID
+}
+
+// Plan computes the ranges self must hand off now that the ring changed
+// from old to new: ranges self used to replicate but isn't the new primary
+// for, addressed to whichever node is. Plan only seeds the handoff to the
+// new primary; the remaining replicas of a changed range catch up through
+// normal anti-entropy once the new primary has the data, rather than Plan
+// trying to reconcile every replica of every changed range in one pass.
+func Plan(old, new hashing.Ring, n int, self hashing.NodeID) []RangeHandoff {
+	var plan []RangeHandoff
+
+	for _, rng := range ChangedRanges(old, new, n) {
+		if !containsNode(old.ReplicasAt(rng.High, n), self) {
+			continue // self never held this range
+		}
+
+		newOwners := new.ReplicasAt(rng.High, n)
+		if len(newOwners) == 0 || newOwners[0] == self {
+			continue // self is still (or newly) primary: nothing to ship
+		}
+
+		plan = append(plan, RangeHandoff{Range: rng, Target: newOwners[0]})
+	}
+
+	return plan
+}
+
+// mergeBoundaries returns the sorted, deduplicated union of a and b.
+func mergeBoundaries(a, b []uint32) []uint32 {
+	set := make(map[uint32]struct{}, len(a)+len(b))
+	for _, v := range a {
+		set[v] = struct{}{}
+	}
+	for _, v := range b {
+		set[v] = struct{}{}
+	}
+
+	merged := make([]uint32, 0, len(set))
+	for v := range set {
+		merged = append(merged, v)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i] < merged[j] })
+	return merged
+}
+
+// sameOwners reports whether a and b are the same replica set in the same
+// order. Order matters here, not just membership: ReplicasAt returns the
+// primary first followed by the walk order of the remaining replicas, so a
+// ring change that reassigns which node is primary without changing set
+// membership (e.g. [A,B,C] -> [B,A,C]) is still an ownership change a
+// caller needs to hand off.
+func sameOwners(a, b []hashing.NodeID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsNode(nodes []hashing.NodeID, target hashing.NodeID) bool {
+	for _, n := range nodes {
+		if n == target {
+			return true
+		}
+	}
+	return false
+}
+
+func formatRangeID(low, high uint32) string {
+	return fmt.Sprintf("%08x-%08x", low, high)
+}
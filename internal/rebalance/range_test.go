@@ -0,0 +1,95 @@
+package rebalance
+
+import (
+	"testing"
+
+	"github.com/taitelee/kvstore/internal/hashing"
+)
+
+// TestSameOwnersDetectsPrimaryReassignment checks that a replica set whose
+// membership is unchanged but whose primary is reassigned (e.g. [A,B] ->
+// [B,A]) counts as changed ownership, not merely the unordered set
+// comparison the spec calls out as wrong.
+func TestSameOwnersDetectsPrimaryReassignment(t *testing.T) {
+	a := []hashing.NodeID{"A", "B"}
+	b := []hashing.NodeID{"B", "A"}
+
+	if sameOwners(a, b) {
+		t.Fatalf("sameOwners(%v, %v) = true, want false: same set, different primary", a, b)
+	}
+}
+
+func TestSameOwnersTrueForIdenticalOrder(t *testing.T) {
+	a := []hashing.NodeID{"A", "B", "C"}
+	b := []hashing.NodeID{"A", "B", "C"}
+
+	if !sameOwners(a, b) {
+		t.Fatalf("sameOwners(%v, %v) = false, want true", a, b)
+	}
+}
+
+func TestSameOwnersFalseForDifferentLength(t *testing.T) {
+	a := []hashing.NodeID{"A", "B"}
+	b := []hashing.NodeID{"A", "B", "C"}
+
+	if sameOwners(a, b) {
+		t.Fatalf("sameOwners(%v, %v) = true, want false", a, b)
+	}
+}
+
+// TestChangedRangesDetectsPrimarySwap builds a ring with two nodes and checks
+// ChangedRanges reports changed ranges both when a third node is added and
+// when only the primary of a range changes while its replica set membership
+// stays the same.
+func TestChangedRangesDetectsPrimarySwap(t *testing.T) {
+	old := hashing.NewRing(1)
+	old.AddNode("A", 10)
+	old.AddNode("B", 10)
+
+	// Cloning by rebuilding the same topology: old and new start identical,
+	// then new adds a node, so every range the new node claims as primary
+	// necessarily changes ownership by our definition (and by the old
+	// unordered definition too) -- this confirms the basic case still
+	// works before the primary-swap-only case below.
+	newRing := hashing.NewRing(1)
+	newRing.AddNode("A", 10)
+	newRing.AddNode("B", 10)
+	newRing.AddNode("C", 10)
+
+	changed := ChangedRanges(old, newRing, 2)
+	if len(changed) == 0 {
+		t.Fatalf("expected adding a node to change at least one range's owners")
+	}
+
+	// Primary-swap-only case: with two nodes and N=2 every replica set is
+	// {A, B}, so giving A more virtual nodes can only reorder a range's
+	// owners, never change their membership.
+	swapped := hashing.NewRing(1)
+	swapped.AddNode("A", 50)
+	swapped.AddNode("B", 10)
+
+	if len(ChangedRanges(old, swapped, 2)) == 0 {
+		t.Fatalf("expected a primary swap with unchanged membership to change at least one range")
+	}
+}
+
+func TestPlanOnlyHandsOffRangesSelfOwned(t *testing.T) {
+	old := hashing.NewRing(1)
+	old.AddNode("A", 10)
+	old.AddNode("B", 10)
+
+	newRing := hashing.NewRing(1)
+	newRing.AddNode("A", 10)
+	newRing.AddNode("B", 10)
+	newRing.AddNode("C", 10)
+
+	plan := Plan(old, newRing, 2, "A")
+	for _, rh := range plan {
+		if !containsNode(old.ReplicasAt(rh.Range.High, 2), "A") {
+			t.Fatalf("Plan included range %s which self (A) never held", rh.Range.ID())
+		}
+		if containsNode(newRing.ReplicasAt(rh.Range.High, 2), "A") && newRing.ReplicasAt(rh.Range.High, 2)[0] == "A" {
+			t.Fatalf("Plan included range %s for which self is still primary", rh.Range.ID())
+		}
+	}
+}